@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAccessTokens lists the caller's personal access tokens, without their secrets
+func GetAccessTokens(c *gin.Context) {
+	account := CurrentAccount(c)
+	tokens := []models.PersonalAccessToken{}
+	dao.GetDB().Where("account_id = ?", account.ID).Order("id").Find(&tokens)
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAccessToken mints a token for the caller. The response is the only time
+// the token itself is shown.
+func CreateAccessToken(c *gin.Context) {
+	account := CurrentAccount(c)
+	var pat models.PersonalAccessToken
+	if err := c.ShouldBindJSON(&pat); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pat.ID = 0
+	pat.AccountID = account.ID
+	pat.LastUsedAt = nil
+	if err := pat.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token data", "details": err.Error()})
+		return
+	}
+
+	if err := models.CreatePersonalAccessToken(&pat); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	c.JSON(http.StatusCreated, pat)
+}
+
+// RevokeAccessToken deletes one of the caller's tokens; it stops working at once
+func RevokeAccessToken(c *gin.Context) {
+	account := CurrentAccount(c)
+	rec := dao.GetDB().Where("account_id = ?", account.ID).Delete(&models.PersonalAccessToken{}, c.Param("id"))
+	if rec.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+	if rec.RowsAffected < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully!"})
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"task-management/dao"
+	"task-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTaskHistory lists what happened to a task newest first, paging like
+// GetActivity. The history of a deleted task stays readable.
+func GetTaskHistory(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	tx := dao.GetDB().Model(&models.Activity{}).Scopes(dao.InWorkspace(CurrentWorkspaceID(c))).Where("task_id = ?", taskID)
+	var count int64
+	if err := tx.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	listActivity(c, tx)
+}
+
+// GetActivity lists what happened to the tasks of the caller's workspace,
+// newest first. It filters on the task_id, actor_id, action and field query
+// parameters and on since and until (RFC 3339), and pages with limit and
+// before, the id of the last entry already seen.
+func GetActivity(c *gin.Context) {
+	tx := dao.GetDB().Model(&models.Activity{}).Scopes(dao.InWorkspace(CurrentWorkspaceID(c)))
+	for _, field := range []string{"task_id", "actor_id"} {
+		raw := c.Query(field)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + field})
+			return
+		}
+		tx = tx.Where(field+" = ?", id)
+	}
+	if action := models.ActivityAction(c.Query("action")); action != "" {
+		if action != models.ActivityCreated && action != models.ActivityUpdated && action != models.ActivityDeleted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "action must be created, updated or deleted"})
+			return
+		}
+		tx = tx.Where("action = ?", action)
+	}
+	if field := c.Query("field"); field != "" {
+		tracked := false
+		for _, f := range models.TrackedTaskFields {
+			tracked = tracked || f == field
+		}
+		if !tracked {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid field"})
+			return
+		}
+		// Changes are stored as JSON; tracked field names need no escaping
+		tx = tx.Where("changes LIKE ?", `%"field":"`+field+`"%`)
+	}
+	for _, bound := range []string{"since", "until"} {
+		raw := c.Query(bound)
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": bound + " must be an RFC 3339 time"})
+			return
+		}
+		if bound == "since" {
+			tx = tx.Where("created_at >= ?", at.UTC())
+		} else {
+			tx = tx.Where("created_at < ?", at.UTC())
+		}
+	}
+	listActivity(c, tx)
+}
+
+// listActivity writes one page of the entries tx selects
+func listActivity(c *gin.Context, tx *gorm.DB) {
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if raw := c.Query("before"); raw != "" {
+		before, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+		tx = tx.Where("id < ?", before)
+	}
+
+	entries := []models.Activity{}
+	if err := tx.Order("id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-management/dao"
+	"task-management/mailer"
+	"task-management/models"
+	u "task-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SuspendAccountRequest struct {
+	Reason string `json:"reason" binding:"max=500"` // Kept in the audit trail
+}
+
+type AdminDeleteAccountRequest struct {
+	TaskPolicy models.TaskPolicy `json:"task_policy" binding:"required"`
+}
+
+// ListAccounts lists accounts newest first with how many tasks each created.
+// q searches emails and display names, status is active, suspended or
+// unverified, and pages work as in GetAuthEvents.
+func ListAccounts(c *gin.Context) {
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := dao.GetDB().Model(&models.Account{}).Select(models.AccountSummaryColumns)
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + u.EscapeLike(strings.ToLower(q)) + "%"
+		tx = tx.Where("(LOWER(accounts.email) LIKE ? ESCAPE '\\' OR LOWER(accounts.display_name) LIKE ? ESCAPE '\\')", pattern, pattern)
+	}
+	switch c.Query("status") {
+	case "":
+	case "active":
+		tx = tx.Where("accounts.suspended_at IS NULL")
+	case "suspended":
+		tx = tx.Where("accounts.suspended_at IS NOT NULL")
+	case "unverified":
+		tx = tx.Where("accounts.verified_at IS NULL")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of active, suspended, unverified"})
+		return
+	}
+	if raw := c.Query("before"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+		tx = tx.Where("accounts.id < ?", id)
+	}
+
+	accounts := []models.AccountSummary{}
+	if err := tx.Order("accounts.id DESC").Limit(limit).Scan(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+		return
+	}
+	if !auditRead(c, models.AuditAccountsListed, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetAccount returns an account with its task counts by status
+func GetAccount(c *gin.Context) {
+	account, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	counts, err := models.TaskCounts(account.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tasks"})
+		return
+	}
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	if !auditRead(c, models.AuditAccountViewed, account) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"account": account, "tasks": gin.H{"total": total, "by_status": counts}})
+}
+
+// SuspendAccount signs an account out and keeps it from signing in until it is reactivated
+func SuspendAccount(c *gin.Context) {
+	var request SuspendAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	account, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	if account.ID == CurrentAccount(c).ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot suspend your own account"})
+		return
+	}
+	if account.Suspended() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is already suspended"})
+		return
+	}
+	suspend := func(tx *gorm.DB) error { return models.SuspendAccount(tx, account.ID) }
+	if !audited(c, models.AuditAccountSuspended, account, request.Reason, suspend) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account suspended successfully!"})
+}
+
+// ReactivateAccount lifts a suspension
+func ReactivateAccount(c *gin.Context) {
+	account, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	if !account.Suspended() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is not suspended"})
+		return
+	}
+	reactivate := func(tx *gorm.DB) error { return models.ReactivateAccount(tx, account.ID) }
+	if !audited(c, models.AuditAccountReactivated, account, "", reactivate) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account reactivated successfully!"})
+}
+
+// DeleteAccount deletes an account as DeleteMe would, with the given task_policy
+func DeleteAccount(c *gin.Context) {
+	var request AdminDeleteAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := request.TaskPolicy.IsValid(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	account, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	if account.ID == CurrentAccount(c).ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delete your own account from /me"})
+		return
+	}
+	remove := func(tx *gorm.DB) error { return models.DeleteAccount(tx, account.ID, request.TaskPolicy) }
+	if !audited(c, models.AuditAccountDeleted, account, "task_policy="+string(request.TaskPolicy), remove) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully!"})
+}
+
+// ForcePasswordReset invalidates an account's password and sessions and emails
+// its owner a link to choose a new password
+func ForcePasswordReset(c *gin.Context) {
+	account, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	var token string
+	reset := func(tx *gorm.DB) (err error) {
+		token, err = models.ForcePasswordReset(tx, account.ID)
+		return err
+	}
+	if !audited(c, models.AuditPasswordResetForced, account, "", reset) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	if err := mailer.Send(forcedResetMessage(account, token)); err != nil {
+		log.Printf("forced password reset for account %d not sent: %v", account.ID, err)
+		c.JSON(http.StatusAccepted, gin.H{"message": "Password reset, but the email could not be sent; the owner can use /password/forgot"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset; the owner has been emailed a link to choose a new one"})
+}
+
+func forcedResetMessage(account *models.Account, token string) mailer.Message {
+	return mailer.Message{
+		To:      account.Email,
+		Subject: "Your password has been reset",
+		Body: fmt.Sprintf("An administrator has reset the password of your account and signed it out everywhere.\n\n"+
+			"POST this token with your new password to %s/password/reset:\n\n%s\n\n"+
+			"It can be used once and expires in %s; after that, use %s/password/forgot.\n",
+			u.AppURL(), token, models.PasswordResetLifetime, u.AppURL()),
+	}
+}
+
+// GetAuditLog lists what administrators have done, newest first. It filters on
+// the actor_id, target_id and action query parameters and pages like GetAuthEvents.
+func GetAuditLog(c *gin.Context) {
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := dao.GetDB().Model(&models.AuditLog{})
+	for _, field := range []string{"actor_id", "target_id", "before"} {
+		raw := c.Query(field)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + field})
+			return
+		}
+		if field == "before" {
+			tx = tx.Where("id < ?", id)
+		} else {
+			tx = tx.Where(field+" = ?", id)
+		}
+	}
+	if action := c.Query("action"); action != "" {
+		tx = tx.Where("action = ?", action)
+	}
+
+	entries := []models.AuditLog{}
+	if err := tx.Order("id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	if !auditRead(c, models.AuditAuditLogViewed, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// UnlockAccount clears the failed logins of an account, lifting any lockout or backoff
+func UnlockAccount(c *gin.Context) {
+	account, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	unlock := func(tx *gorm.DB) error { return models.ClearLoginFailures(tx, models.AccountThrottleKey(account.ID)) }
+	if !audited(c, models.AuditAccountUnlocked, account, "", unlock) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+	models.RecordAuthEvent(authEvent(c, models.EventAccountUnlocked, account, account.Email, "unlocked by "+CurrentAccount(c).Email))
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully!"})
+}
+
+// GetAuthEvents lists the auth event log newest first. It filters on the
+// account_id, email, ip and type query parameters and pages with limit and
+// before, the id of the last event already seen.
+func GetAuthEvents(c *gin.Context) {
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := dao.GetDB().Model(&models.AuthEvent{})
+	if raw := c.Query("account_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account_id"})
+			return
+		}
+		tx = tx.Where("account_id = ?", id)
+	}
+	for _, field := range []string{"email", "ip", "type"} {
+		if value := c.Query(field); value != "" {
+			tx = tx.Where(field+" = ?", value)
+		}
+	}
+	if raw := c.Query("before"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+			return
+		}
+		tx = tx.Where("id < ?", id)
+	}
+
+	events := []models.AuthEvent{}
+	if err := tx.Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch auth events"})
+		return
+	}
+	if !auditRead(c, models.AuditAuthEventsViewed, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// adminTarget loads the account named by the id path parameter, writing a 404 when there is none
+func adminTarget(c *gin.Context) (*models.Account, bool) {
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("id = ?", c.Param("id")).First(account).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return nil, false
+	}
+	account.Password = ""
+	return account, true
+}
+
+// audited makes a change with the action the caller took on target recorded
+// in the audit trail, both in one transaction so neither happens without the
+// other. It reports whether they were made.
+func audited(c *gin.Context, action models.AuditAction, target *models.Account, detail string, change func(tx *gorm.DB) error) bool {
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+		return models.RecordAudit(tx, auditEntry(c, action, target, detail))
+	})
+	if err != nil {
+		log.Printf("%s by account %d not made: %v", action, CurrentAccount(c).ID, err)
+		return false
+	}
+	return true
+}
+
+// auditRead records that the caller read data about target, or about many
+// accounts when target is nil, before the response goes out. The query is kept
+// as the detail. It writes the error response when it returns false.
+func auditRead(c *gin.Context, action models.AuditAction, target *models.Account) bool {
+	if err := models.RecordAudit(dao.GetDB(), auditEntry(c, action, target, c.Request.URL.RawQuery)); err != nil {
+		log.Printf("%s by account %d not recorded: %v", action, CurrentAccount(c).ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record access"})
+		return false
+	}
+	return true
+}
+
+func auditEntry(c *gin.Context, action models.AuditAction, target *models.Account, detail string) *models.AuditLog {
+	actor := CurrentAccount(c)
+	entry := &models.AuditLog{
+		ActorID:    actor.ID,
+		ActorEmail: actor.Email,
+		Action:     action,
+		Detail:     detail,
+		IP:         c.ClientIP(),
+	}
+	if target != nil {
+		entry.TargetID, entry.TargetEmail = target.ID, target.Email
+	}
+	return entry
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"task-management/dao"
+	"task-management/models"
+	"task-management/storage"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultMaxAttachmentSize = 10 << 20
+	maxFilenameLength        = 255
+)
+
+// Content types accepted unless attachment_types lists others
+var defaultAttachmentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain", "application/zip"}
+
+// maxAttachmentSize is attachment_max_size in bytes, 10 MiB by default
+func maxAttachmentSize() int64 {
+	if raw := os.Getenv("attachment_max_size"); raw != "" {
+		if size, err := strconv.ParseInt(raw, 10, 64); err == nil && size > 0 {
+			return size
+		}
+		log.Printf("ignoring invalid attachment_max_size %q", raw)
+	}
+	return defaultMaxAttachmentSize
+}
+
+// attachmentTypeAllowed checks a content type against the comma separated
+// attachment_types, ignoring its parameters
+func attachmentTypeAllowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	allowed := defaultAttachmentTypes
+	if raw := os.Getenv("attachment_types"); raw != "" {
+		allowed = strings.Split(raw, ",")
+	}
+	for _, t := range allowed {
+		if strings.EqualFold(strings.TrimSpace(t), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFilename keeps the last element of an uploaded file's name, without
+// control characters
+func cleanFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if len(name) > maxFilenameLength {
+		name = name[:maxFilenameLength]
+	}
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+// GetAttachments lists a task's attachments oldest first
+func GetAttachments(c *gin.Context) {
+	task, ok := findTask(c)
+	if !ok {
+		return
+	}
+	attachments := []models.Attachment{}
+	if err := dao.GetDB().Where("task_id = ?", task.ID).Order("id").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// CreateAttachment attaches the multipart file field to a task. The content type
+// is detected from the contents and must be allowed by attachment_types.
+func CreateAttachment(c *gin.Context) {
+	task, ok := findTask(c)
+	if !ok {
+		return
+	}
+	maxSize := maxAttachmentSize()
+	// Leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+		return
+	}
+	if header.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment is too large"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the file"})
+		return
+	}
+	if int64(len(content)) > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment is too large"})
+		return
+	}
+
+	contentType := http.DetectContentType(content)
+	if !attachmentTypeAllowed(contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Attachments of type " + contentType + " are not allowed"})
+		return
+	}
+
+	attachment := models.Attachment{TaskID: task.ID, AccountID: CurrentAccount(c).ID, Filename: cleanFilename(header.Filename), ContentType: contentType}
+	err = models.CreateAttachment(c.Request.Context(), storage.GetStore(), &attachment, content)
+	switch {
+	case errors.Is(err, models.ErrAttachmentEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("could not store attachment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// DownloadAttachment sends an attachment's contents as a download
+func DownloadAttachment(c *gin.Context) {
+	attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	content, err := storage.GetStore().Get(c.Request.Context(), models.BlobKey(attachment.SHA256))
+	if err != nil {
+		log.Printf("could not read blob of attachment %d: %v", attachment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", `"`+attachment.SHA256+`"`)
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, nil)
+}
+
+// DeleteAttachment removes an attachment from its task
+func DeleteAttachment(c *gin.Context) {
+	attachment, ok := findAttachment(c)
+	if !ok {
+		return
+	}
+	if err := models.DeleteAttachment(attachment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting attachment!"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully!"})
+}
+
+// findAttachment loads the attachment named by the attachmentId parameter,
+// which must belong to the task named by id
+func findAttachment(c *gin.Context) (*models.Attachment, bool) {
+	task, ok := findTask(c)
+	if !ok {
+		return nil, false
+	}
+	attachment := &models.Attachment{}
+	if err := dao.GetDB().Where("task_id = ?", task.ID).First(attachment, c.Param("attachmentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	return attachment, true
+}
@@ -1,10 +1,11 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"task-management/dao"
 	"task-management/models"
@@ -14,11 +15,10 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
-// Secret key for signing the JWT token (should be stored securely in an environment variable)
+// Secret tokens were signed with before signing keys; see acceptLegacyTokens
 var jwtSecret []byte
 
 func init() {
@@ -31,6 +31,14 @@ func init() {
 	jwtSecret = []byte(os.Getenv("token_password"))
 }
 
+// Keys under which Authenticate stores the caller's account, token claims and
+// personal access token scopes in the gin context
+const (
+	accountKey = "account"
+	claimsKey  = "claims"
+	scopesKey  = "scopes"
+)
+
 func CreateAccount(c *gin.Context) {
 
 	account := &models.Account{}
@@ -40,6 +48,13 @@ func CreateAccount(c *gin.Context) {
 	}
 
 	resp := account.Create() //Create account
+	if created, _ := resp["status"].(bool); created {
+		// The account stays unverified until its owner follows the emailed link
+		if err := sendVerification(account); err != nil {
+			log.Printf("verification for account %d not sent: %v", account.ID, err)
+		}
+		resp["message"] = "Account has been created; check your email to verify it"
+	}
 	c.JSON(http.StatusCreated, resp)
 }
 
@@ -63,37 +78,144 @@ func Authenticate() gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Inside the callback function checks if the token uses HMAC signing.
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signing method"})
-				c.Abort()
+		// Personal access tokens and JWTs are both accepted
+		var account *models.Account
+		var ok bool
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			account, ok = authenticateAccessToken(c, tokenString)
+		} else {
+			account, ok = authenticateJWT(c, tokenString)
+		}
+		if !ok {
+			c.Abort()
+			return
+		}
+		// Impersonation tokens act for the account on behalf of an administrator
+		if claims := tokenClaims(c); claims[actClaim] != nil {
+			admin, ok := authenticateImpersonation(c, claims)
+			if admin != nil {
+				entry := recordImpersonatedRequest(c, admin, account)
+				if entry == nil {
+					c.Abort()
+					return
+				}
+				defer recordImpersonatedResponse(c, entry)
 			}
-			// This is mainly for if statements.  When the app is running, this should already be initialized.
-			if jwtSecret == nil {
-				jwtSecret = []byte(os.Getenv("token_password"))
-			} else if len(jwtSecret) == 0 {
-				jwtSecret = []byte(os.Getenv("token_password"))
+			if !ok {
+				c.Abort()
+				return
 			}
-			fmt.Println(jwtSecret)
-			return jwtSecret, nil
-		})
-		if err != nil {
-			println("Error: " + err.Error())
 		}
-		// If token is invalid
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		if account.Suspended() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Account suspended",
+				"code":  "account_suspended",
+			})
+			return
+		}
+		if err := models.EnsureActiveWorkspace(account); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workspace"})
 			c.Abort()
 			return
 		}
+		c.Set(accountKey, account)
 
 		// Token is valid, continue request
 		c.Next()
 	}
 }
 
+// authenticateJWT resolves a signed access token to its account, writing a 401
+// when the token is not acceptable.
+func authenticateJWT(c *gin.Context, tokenString string) (*models.Account, bool) {
+	// Parse and validate token; single-purpose tokens cannot be used for access,
+	// and access tokens must have an id that has not been revoked
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		println("Error: " + err.Error())
+	}
+	jti, _ := claims["jti"].(string)
+	if err != nil || claims[purposeClaim] != nil || jti == "" || models.AccessTokenRevoked(jti) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return nil, false
+	}
+
+	// Resolve the username claim to the account making the request
+	username, _ := claims["username"].(string)
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("email = ?", username).First(account).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found"})
+		return nil, false
+	}
+	account.Password = ""
+	if iat, _ := claims["iat"].(float64); account.PasswordChangedAt != nil && int64(iat) < account.PasswordChangedAt.Unix() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return nil, false
+	}
+	c.Set(claimsKey, claims)
+	return account, true
+}
+
+// authenticateAccessToken resolves a personal access token to its account. The
+// token's scopes are kept for Require to check.
+func authenticateAccessToken(c *gin.Context, tokenString string) (*models.Account, bool) {
+	pat, err := models.UsePersonalAccessToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return nil, false
+	}
+	account := models.GetUser(pat.AccountID)
+	if account == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account not found"})
+		return nil, false
+	}
+	c.Set(scopesKey, pat.Scopes)
+	return account, true
+}
+
+// SessionOnly keeps personal access tokens and impersonation away from routes
+// that manage the account itself; those need a login. It must run after Authenticate.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := tokenScopes(c); ok || Impersonator(c) != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Forbidden",
+				"code":  "session_required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// tokenScopes returns the scopes of the personal access token the request was
+// authenticated with; ok is false for logged in sessions.
+func tokenScopes(c *gin.Context) (models.TokenScopes, bool) {
+	if value, ok := c.Get(scopesKey); ok {
+		scopes, ok := value.(models.TokenScopes)
+		return scopes, ok
+	}
+	return nil, false
+}
+
+// CurrentAccount returns the account resolved by Authenticate for this request.
+func CurrentAccount(c *gin.Context) *models.Account {
+	if value, ok := c.Get(accountKey); ok {
+		if account, ok := value.(*models.Account); ok {
+			return account
+		}
+	}
+	return nil
+}
+
+// CurrentWorkspaceID returns the workspace the caller's request acts on.
+func CurrentWorkspaceID(c *gin.Context) uint {
+	if account := CurrentAccount(c); account != nil && account.ActiveWorkspaceID != nil {
+		return *account.ActiveWorkspaceID
+	}
+	return 0
+}
+
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -108,39 +230,145 @@ func Login(c *gin.Context) {
 		return
 	}
 	var msg map[string]interface{}
+	// Clients backing off after failed attempts are refused before any checks
+	if !loginAllowed(c, nil, loginRequest.Email) {
+		return
+	}
 	err := dao.GetDB().Table("accounts").Where("email = ?", loginRequest.Email).First(account).Error
 	if err != nil {
 
 		if err == gorm.ErrRecordNotFound {
+			loginFailed(c, nil, loginRequest.Email, "unknown email")
 			msg = u.Message(false, "Email address not found")
 		} else {
 			msg = u.Message(false, "Connection error. Please retry")
 		}
 		c.JSON(http.StatusUnauthorized, msg)
+		return
+	}
+	if !loginAllowed(c, account, loginRequest.Email) {
+		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(loginRequest.Password))
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword { //Password does not match!
+	if !account.CheckPassword(loginRequest.Password) { //Password does not match!
+		loginFailed(c, account, loginRequest.Email, "wrong password")
 		msg = u.Message(false, "Invalid login credentials. Please try again")
 		c.JSON(http.StatusUnauthorized, msg)
+		return
+	}
+	if !account.Verified() {
+		c.JSON(http.StatusForbidden, u.Message(false, "Email address has not been verified"))
+		return
+	}
+	if refuseSuspended(c, account) {
+		return
+	}
+	//Accounts with two-factor login get a challenge to answer at /login/2fa instead
+	if account.TwoFactorEnabled() {
+		twoFactorChallenge(c, account)
+		return
 	}
 	//Worked! Logged In
 	account.Password = ""
 
-	//Create JWT token
-	claims := jwt.MapClaims{
-		"username": account.Email,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
-	}
-	// Create a JWT token with an expiration time
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	//Create the access and refresh tokens of a new session
+	tokens, err := issueTokens(account, "")
 	if err != nil {
 		println("Token signing error: " + err.Error())
-		c.Abort()
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to sign in. Please retry"))
+		return
 	}
 
+	loginSucceeded(c, account)
+
 	resp := u.Message(true, "Logged In")
-	resp["token"] = tokenString
+	for key, value := range tokens {
+		resp[key] = value
+	}
 	c.JSON(http.StatusOK, resp)
 }
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token. A
+// refresh token only works once; presenting it again revokes its whole session.
+func RefreshToken(c *gin.Context) {
+	var request RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	rotated, err := models.RotateRefreshToken(request.RefreshToken)
+	if errors.Is(err, models.ErrRefreshTokenReuse) {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Refresh token was already used; the session has been revoked"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Invalid or expired refresh token"))
+		return
+	}
+	account := models.GetUser(rotated.AccountID)
+	if account == nil {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Account not found"))
+		return
+	}
+	if refuseSuspended(c, account) {
+		return
+	}
+
+	tokens, err := issueTokens(account, rotated.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to refresh token. Please retry"))
+		return
+	}
+	resp := u.Message(true, "Token refreshed")
+	for key, value := range tokens {
+		resp[key] = value
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout revokes the caller's session, or with ?all=true every session of the account
+func Logout(c *gin.Context) {
+	claims := tokenClaims(c)
+	var err error
+	if all, _ := strconv.ParseBool(c.Query("all")); all {
+		err = models.RevokeAccountSessions(CurrentAccount(c).ID)
+	} else if sid, _ := claims["sid"].(string); sid != "" {
+		err = models.RevokeSession(sid)
+	}
+	if err == nil {
+		// The token used for this request goes too, even if it has no session
+		jti, _ := claims["jti"].(string)
+		exp, _ := claims["exp"].(float64)
+		err = models.DenyAccessToken(jti, time.Unix(int64(exp), 0))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to log out. Please retry"))
+		return
+	}
+	c.JSON(http.StatusOK, u.Message(true, "Logged out"))
+}
+
+// refuseSuspended answers with a 403 when account has been suspended, so it
+// cannot start or renew a session
+func refuseSuspended(c *gin.Context, account *models.Account) bool {
+	if !account.Suspended() {
+		return false
+	}
+	c.JSON(http.StatusForbidden, u.Message(false, "Account has been suspended"))
+	return true
+}
+
+// tokenClaims returns the claims of the access token Authenticate accepted.
+func tokenClaims(c *gin.Context) jwt.MapClaims {
+	if value, ok := c.Get(claimsKey); ok {
+		if claims, ok := value.(jwt.MapClaims); ok {
+			return claims
+		}
+	}
+	return jwt.MapClaims{}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Key under which the permission middleware stores the caller's role
+const roleKey = "role"
+
+// Require lets a request through when the caller's role in their active
+// workspace grants permission. It must run after Authenticate.
+func Require(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorize(c, CurrentWorkspaceID(c), permission)
+	}
+}
+
+// RequireOnWorkspace is Require for routes that name the workspace they act on
+// in their :id parameter. Callers outside that workspace get a 404.
+func RequireOnWorkspace(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspaceID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			return
+		}
+		authorize(c, uint(workspaceID), permission)
+	}
+}
+
+func authorize(c *gin.Context, workspaceID uint, permission models.Permission) {
+	role, err := models.MemberRole(workspaceID, CurrentAccount(c).ID)
+	if errors.Is(err, models.ErrNotMember) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	c.Set(roleKey, role)
+	if !role.Can(permission) {
+		forbidden(c, workspaceID, role, permission)
+		c.Abort()
+		return
+	}
+	// Personal access tokens are further limited to their scopes
+	if scopes, ok := tokenScopes(c); ok && !scopes.Has(permission.Scope()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":      "Forbidden",
+			"code":       "insufficient_scope",
+			"permission": permission,
+			"scopes":     scopes,
+		})
+		return
+	}
+	c.Next()
+}
+
+// forbidden writes the structured 403 for a caller whose role lacks permission
+func forbidden(c *gin.Context, workspaceID uint, role models.Role, permission models.Permission) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":        "Forbidden",
+		"code":         "permission_denied",
+		"permission":   permission,
+		"role":         role,
+		"workspace_id": workspaceID,
+	})
+}
+
+// RequireAdmin lets only site administrators through. It must run after Authenticate.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if account := CurrentAccount(c); account == nil || !account.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Forbidden",
+				"code":  "admin_required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// can reports whether the caller may also do permission in the workspace the
+// request was authorized on, within the scopes of a personal access token
+func can(c *gin.Context, permission models.Permission) bool {
+	if !CurrentRole(c).Can(permission) {
+		return false
+	}
+	if scopes, ok := tokenScopes(c); ok {
+		return scopes.Has(permission.Scope())
+	}
+	return true
+}
+
+// CurrentRole returns the caller's role in the workspace the request was authorized on.
+func CurrentRole(c *gin.Context) models.Role {
+	if value, ok := c.Get(roleKey); ok {
+		if role, ok := value.(models.Role); ok {
+			return role
+		}
+	}
+	return ""
+}
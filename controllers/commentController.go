@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"task-management/dao"
+	"task-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommentRequest struct {
+	Body     string `json:"body" binding:"required"` // Markdown
+	ParentID *uint  `json:"parent_id"`               // Top-level comment to reply to
+}
+
+type EditCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// CommentPage is the envelope returned by comment listings
+type CommentPage struct {
+	Data       []models.Comment `json:"data"`
+	Total      int64            `json:"total"` // Top-level comments, replies are not counted
+	NextCursor *string          `json:"next_cursor"`
+}
+
+// GetComments lists a task's top-level comments oldest first, each with its
+// replies. Pages hold limit threads; cursor continues from next_cursor.
+func GetComments(c *gin.Context) {
+	task, ok := findTask(c)
+	if !ok {
+		return
+	}
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var page CommentPage
+	if err := models.TopLevelComments(task.ID).Count(&page.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count comments"})
+		return
+	}
+	query := models.TopLevelComments(task.ID)
+	if raw := c.Query("cursor"); raw != "" {
+		after, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = query.Where("id > ?", after)
+	}
+
+	// One extra row tells whether there is a next page
+	page.Data = []models.Comment{}
+	if err := query.Order("id").Limit(limit + 1).Find(&page.Data).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	if len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		next := strconv.FormatUint(uint64(page.Data[limit-1].ID), 10)
+		page.NextCursor = &next
+	}
+	if err := models.LoadThreads(page.Data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// CreateComment comments on a task, or replies to one of its comments
+func CreateComment(c *gin.Context) {
+	task, ok := findTask(c)
+	if !ok {
+		return
+	}
+	var request CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment := models.Comment{TaskID: task.ID, AccountID: CurrentAccount(c).ID, ParentID: request.ParentID, Body: request.Body}
+	err := models.CreateComment(&comment)
+	switch {
+	case errors.Is(err, models.ErrInvalidCommentBody), errors.Is(err, models.ErrInvalidCommentParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	created := []models.Comment{comment}
+	models.LoadThreads(created)
+	c.JSON(http.StatusCreated, created[0])
+}
+
+// UpdateComment edits a comment's body; only its author can
+func UpdateComment(c *gin.Context) {
+	comment, ok := findComment(c)
+	if !ok {
+		return
+	}
+	var request EditCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if comment.AccountID != CurrentAccount(c).ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a comment"})
+		return
+	}
+
+	err := models.EditComment(comment, request.Body)
+	switch {
+	case errors.Is(err, models.ErrInvalidCommentBody):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	updated := []models.Comment{*comment}
+	models.LoadThreads(updated)
+	c.JSON(http.StatusOK, updated[0])
+}
+
+// GetCommentRevisions lists the earlier bodies of a comment, newest first
+func GetCommentRevisions(c *gin.Context) {
+	comment, ok := findComment(c)
+	if !ok {
+		return
+	}
+	revisions, err := models.CommentRevisions(comment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// DeleteComment soft deletes a comment. Authors can delete their own comments,
+// workspace admins anyone's.
+func DeleteComment(c *gin.Context) {
+	comment, ok := findComment(c)
+	if !ok {
+		return
+	}
+	if comment.AccountID != CurrentAccount(c).ID && !can(c, models.PermCommentAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author or a workspace admin can delete a comment"})
+		return
+	}
+	if err := dao.GetDB().Delete(comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting comment!"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully!"})
+}
+
+// findTask loads the task named by the id parameter from the caller's workspace
+func findTask(c *gin.Context) (*models.Task, bool) {
+	task := &models.Task{}
+	if err := dao.GetDB().Scopes(dao.InWorkspace(CurrentWorkspaceID(c))).First(task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return nil, false
+	}
+	return task, true
+}
+
+// findComment loads the comment named by the commentId parameter, which must
+// belong to the task named by id and not have been deleted
+func findComment(c *gin.Context) (*models.Comment, bool) {
+	task, ok := findTask(c)
+	if !ok {
+		return nil, false
+	}
+	comment := &models.Comment{}
+	if err := dao.GetDB().Where("task_id = ?", task.ID).First(comment, c.Param("commentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	return comment, true
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AddBlockerRequest struct {
+	BlockerID uint `json:"blocker_id" binding:"required"`
+}
+
+func GetTaskDependencies(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var task models.Task
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found!"})
+		return
+	}
+
+	blockedBy, err := models.Blockers(task.ID, false)
+	if err == nil {
+		var blocks []models.Task
+		if blocks, err = models.Blocking(task.ID); err == nil {
+			c.JSON(http.StatusOK, gin.H{"task": task, "blocked_by": blockedBy, "blocks": blocks})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dependencies"})
+}
+
+func AddBlocker(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var task models.Task
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var request AddBlockerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&models.Task{}, request.BlockerID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Blocking task not found"})
+		return
+	}
+	dependency := models.TaskDependency{TaskID: task.ID, BlockerID: request.BlockerID}
+	if err := models.AddDependency(workspace, &dependency); err != nil {
+		if errors.Is(err, models.ErrDependencyCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dependency"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, dependency)
+}
+
+func RemoveBlocker(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var task models.Task
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	rec := dao.GetDB().Where("task_id = ? AND blocker_id = ?", task.ID, c.Param("blockerId")).Delete(&models.TaskDependency{})
+	if rec.Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error removing dependency!"})
+		return
+	} else if rec.RowsAffected < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dependency not found!"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed successfully!"})
+}
+
+func GetDependencyGraph(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	graph, err := models.LoadDependencyGraph(dao.InWorkspace(workspace))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dependencies"})
+		return
+	}
+	c.JSON(http.StatusOK, graph)
+}
+
+// GetExecutionOrder lists the tasks taking part in dependencies so that every
+// task comes after all of its blockers
+func GetExecutionOrder(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	graph, err := models.LoadDependencyGraph(dao.InWorkspace(workspace))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dependencies"})
+		return
+	}
+	order, err := graph.ExecutionOrder()
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": order})
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"task-management/dao"
+	"task-management/models"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Lifetime of an impersonation token that does not ask for one
+const defaultImpersonationLifetime = 15 * time.Minute
+
+const (
+	// Names the administrator acting through an impersonation token, as
+	// {"sub": "<account id>", "email": ...} after RFC 8693
+	actClaim = "act"
+	// Impersonation tokens are read-only unless minted with allow_writes
+	allowWritesClaim = "allow_writes"
+)
+
+// Key under which Authenticate stores the administrator behind an impersonated request
+const impersonatorKey = "impersonator"
+
+// Header marking responses to impersonated requests
+const impersonatedByHeader = "X-Impersonated-By"
+
+type ImpersonateRequest struct {
+	Reason      string `json:"reason" binding:"required,max=500"`        // Kept in the audit trail
+	Minutes     int    `json:"minutes" binding:"omitempty,min=1,max=60"` // Defaults to 15
+	AllowWrites bool   `json:"allow_writes"`
+}
+
+// Impersonate mints a short-lived access token that acts as another account.
+// The token has no refresh token and cannot reach the routes that manage the
+// account itself; every request made with it is written to the audit trail.
+func Impersonate(c *gin.Context) {
+	var request ImpersonateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	target, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	admin := CurrentAccount(c)
+	switch {
+	case target.ID == admin.ID:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot impersonate yourself"})
+		return
+	case target.IsAdmin:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Administrators cannot be impersonated"})
+		return
+	case target.Suspended():
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is suspended"})
+		return
+	}
+
+	lifetime := defaultImpersonationLifetime
+	if request.Minutes > 0 {
+		lifetime = time.Duration(request.Minutes) * time.Minute
+	}
+	now := time.Now()
+	expiresAt := now.Add(lifetime)
+	token, err := signToken(jwt.MapClaims{
+		"username":       target.Email,
+		"jti":            models.RandomToken(16),
+		"iat":            now.Unix(),
+		"exp":            expiresAt.Unix(),
+		actClaim:         map[string]interface{}{"sub": strconv.FormatUint(uint64(admin.ID), 10), "email": admin.Email},
+		allowWritesClaim: request.AllowWrites,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+
+	writes := "read-only"
+	if request.AllowWrites {
+		writes = "writes allowed"
+	}
+	// The token is only handed out once the audit trail has it
+	entry := auditEntry(c, models.AuditImpersonationStarted, target, fmt.Sprintf("%s (%s, %s)", request.Reason, writes, lifetime))
+	if err := models.RecordAudit(dao.GetDB(), entry); err != nil {
+		log.Printf("impersonation of account %d by account %d not recorded: %v", target.ID, admin.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record impersonation"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token":        token,
+		"token_type":   "Bearer",
+		"expires_in":   int(lifetime.Seconds()),
+		"expires_at":   expiresAt,
+		"allow_writes": request.AllowWrites,
+	})
+}
+
+// authenticateImpersonation checks the administrator named by the act claim of
+// an impersonation token, marks the response and holds back writes the token
+// does not allow. It writes the error response when it returns false.
+func authenticateImpersonation(c *gin.Context, claims jwt.MapClaims) (*models.Account, bool) {
+	act, _ := claims[actClaim].(map[string]interface{})
+	sub, _ := act["sub"].(string)
+	id, err := strconv.ParseUint(sub, 10, 0)
+	var admin *models.Account
+	if err == nil {
+		admin = models.GetUser(uint(id))
+	}
+	// Impersonation ends as soon as its administrator is no longer one
+	if admin == nil || !admin.IsAdmin || admin.Suspended() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return nil, false
+	}
+	c.Set(impersonatorKey, admin)
+	c.Header(impersonatedByHeader, admin.Email)
+
+	if allowWrites, _ := claims[allowWritesClaim].(bool); !allowWrites && !readOnlyMethod(c.Request.Method) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Impersonation is read-only",
+			"code":  "impersonation_read_only",
+		})
+		return admin, false
+	}
+	return admin, true
+}
+
+func readOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// recordImpersonatedRequest writes a request made through an impersonation
+// token to the audit trail before it is handled. When that fails the request
+// is refused and nil returned.
+func recordImpersonatedRequest(c *gin.Context, admin, account *models.Account) *models.AuditLog {
+	entry := &models.AuditLog{
+		ActorID:     admin.ID,
+		ActorEmail:  admin.Email,
+		Action:      models.AuditImpersonatedRequest,
+		TargetID:    account.ID,
+		TargetEmail: account.Email,
+		Detail:      c.Request.Method + " " + c.Request.URL.RequestURI(),
+		IP:          c.ClientIP(),
+	}
+	if err := models.RecordAudit(dao.GetDB(), entry); err != nil {
+		log.Printf("request by account %d impersonating account %d not recorded: %v", admin.ID, account.ID, err)
+		if !c.Writer.Written() {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record impersonated request"})
+		}
+		return nil
+	}
+	return entry
+}
+
+// recordImpersonatedResponse adds how the request was answered to its entry
+func recordImpersonatedResponse(c *gin.Context, entry *models.AuditLog) {
+	detail := fmt.Sprintf("%s -> %d", entry.Detail, c.Writer.Status())
+	if err := dao.GetDB().Model(entry).Update("detail", detail).Error; err != nil {
+		log.Printf("answer to impersonated request %d not recorded: %v", entry.ID, err)
+	}
+}
+
+// Impersonator returns the administrator acting through an impersonation token
+// for this request, or nil when the account holder is making it
+func Impersonator(c *gin.Context) *models.Account {
+	if value, ok := c.Get(impersonatorKey); ok {
+		if admin, ok := value.(*models.Account); ok {
+			return admin
+		}
+	}
+	return nil
+}
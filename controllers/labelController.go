@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetLabels(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	labels := []models.Label{}
+	dao.GetDB().Scopes(dao.InWorkspace(workspace)).Order("name").Find(&labels)
+	c.JSON(http.StatusOK, labels)
+}
+
+func CreateLabel(c *gin.Context) {
+	account := CurrentAccount(c)
+	var label models.Label
+	if err := c.ShouldBindJSON(&label); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	label.ID = 0
+	label.AccountID = account.ID
+	label.WorkspaceID = CurrentWorkspaceID(c)
+
+	if labelNameTaken(&label) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Label with this name already exists"})
+		return
+	}
+	if err := dao.GetDB().Create(&label).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create label"})
+		return
+	}
+	c.JSON(http.StatusCreated, label)
+}
+
+func UpdateLabel(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var label models.Label
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&label, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+	labelID, accountID := label.ID, label.AccountID
+
+	if err := c.ShouldBindJSON(&label); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	label.ID, label.AccountID, label.WorkspaceID = labelID, accountID, workspace
+	if label.Color == "" {
+		label.Color = models.DefaultLabelColor
+	}
+
+	if labelNameTaken(&label) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Label with this name already exists"})
+		return
+	}
+	if err := dao.GetDB().Save(&label).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update label"})
+		return
+	}
+	c.JSON(http.StatusOK, label)
+}
+
+func DeleteLabel(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var label models.Label
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&label, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found!"})
+		return
+	}
+
+	// Detach the label from its tasks before removing it
+	err := dao.GetDB().Exec("DELETE FROM task_labels WHERE label_id = ?", label.ID).Error
+	if err == nil {
+		err = dao.GetDB().Delete(&label).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error Deleting label!"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Label deleted successfully!"})
+}
+
+func AttachLabel(c *gin.Context) {
+	task, label, ok := findTaskAndLabel(c)
+	if !ok {
+		return
+	}
+	if err := dao.GetDB().Model(task).Omit("Labels.*").Association("Labels").Append(label); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach label"})
+		return
+	}
+	dao.GetDB().Preload("Labels").First(task, task.ID)
+	c.JSON(http.StatusOK, task)
+}
+
+func DetachLabel(c *gin.Context) {
+	task, label, ok := findTaskAndLabel(c)
+	if !ok {
+		return
+	}
+	if err := dao.GetDB().Model(task).Association("Labels").Delete(label); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detach label"})
+		return
+	}
+	dao.GetDB().Preload("Labels").First(task, task.ID)
+	c.JSON(http.StatusOK, task)
+}
+
+// findTaskAndLabel loads the task and label named in the URL, both of which
+// must belong to the caller's workspace. It writes the error response when either is missing.
+func findTaskAndLabel(c *gin.Context) (*models.Task, *models.Label, bool) {
+	workspace := CurrentWorkspaceID(c)
+	task := &models.Task{}
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return nil, nil, false
+	}
+	label := &models.Label{}
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(label, c.Param("labelId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return nil, nil, false
+	}
+	return task, label, true
+}
+
+// labelNameTaken reports whether another label of the same workspace has this name
+func labelNameTaken(label *models.Label) bool {
+	var count int64
+	dao.GetDB().Model(&models.Label{}).Scopes(dao.InWorkspace(label.WorkspaceID)).
+		Where("name = ? AND id <> ?", label.Name, label.ID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"task-management/dao"
+	"task-management/models"
+	u "task-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginAllowed writes a 429 and records the refusal when the client's address,
+// or account when known, is backing off after failed logins.
+func loginAllowed(c *gin.Context, account *models.Account, email string) bool {
+	keys := []string{models.IPThrottleKey(c.ClientIP())}
+	if account != nil {
+		keys = append(keys, models.AccountThrottleKey(account.ID))
+	}
+	wait, locked := models.LoginBlocked(keys...)
+	if wait <= 0 {
+		return true
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	models.RecordAuthEvent(authEvent(c, models.EventLoginThrottled, account, email, ""))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	msg := u.Message(false, "Too many failed login attempts. Please try again later")
+	if locked {
+		msg = u.Message(false, "Login is temporarily locked after too many failed attempts")
+	}
+	msg["locked"] = locked
+	msg["retry_after"] = seconds
+	c.JSON(http.StatusTooManyRequests, msg)
+	return false
+}
+
+// loginFailed counts a failed attempt against the client's address and the
+// account, when the email matched one, and logs it.
+func loginFailed(c *gin.Context, account *models.Account, email, reason string) {
+	models.RecordAuthEvent(authEvent(c, models.EventLoginFailed, account, email, reason))
+	if _, err := models.RecordLoginFailure(models.IPThrottleKey(c.ClientIP()), models.IPThrottle); err != nil {
+		log.Printf("login failure from %s not counted: %v", c.ClientIP(), err)
+	}
+	if account == nil {
+		return
+	}
+	locked, err := models.RecordLoginFailure(models.AccountThrottleKey(account.ID), models.AccountThrottle)
+	if err != nil {
+		log.Printf("login failure for account %d not counted: %v", account.ID, err)
+	}
+	if locked {
+		models.RecordAuthEvent(authEvent(c, models.EventAccountLocked, account, email, ""))
+	}
+}
+
+// confirmPassword checks the password a signed in caller gives to confirm a
+// change, throttled like a login so a stolen access token cannot be used to
+// guess it. A wrong one is answered with the wrong message. It writes the
+// error response when it returns false.
+func confirmPassword(c *gin.Context, account *models.Account, password, wrong string) bool {
+	if !loginAllowed(c, account, account.Email) {
+		return false
+	}
+	if !account.CheckPassword(password) {
+		loginFailed(c, account, account.Email, "wrong password")
+		c.JSON(http.StatusUnauthorized, u.Message(false, wrong))
+		return false
+	}
+	return true
+}
+
+// confirmSecondFactor checks a two-factor code after confirmPassword, counting
+// a wrong one the same way
+func confirmSecondFactor(c *gin.Context, account *models.Account, code string) bool {
+	if err := models.VerifySecondFactor(account, code); err != nil {
+		loginFailed(c, account, account.Email, "wrong two-factor code")
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Invalid two-factor code"))
+		return false
+	}
+	return true
+}
+
+// loginSucceeded forgets the account's failed attempts. The address keeps its
+// count, so one working login does not reset credential stuffing from it.
+func loginSucceeded(c *gin.Context, account *models.Account) {
+	if err := models.ClearLoginFailures(dao.GetDB(), models.AccountThrottleKey(account.ID)); err != nil {
+		log.Printf("login failures of account %d not cleared: %v", account.ID, err)
+	}
+	models.RecordAuthEvent(authEvent(c, models.EventLoginSucceeded, account, account.Email, ""))
+}
+
+func authEvent(c *gin.Context, eventType models.AuthEventType, account *models.Account, email, detail string) models.AuthEvent {
+	event := models.AuthEvent{Type: eventType, Email: email, IP: c.ClientIP(), Detail: detail}
+	if account != nil {
+		event.AccountID = &account.ID
+	}
+	return event
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+	u "task-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DeleteAccountRequest struct {
+	Password   string            `json:"password" binding:"required"`
+	TaskPolicy models.TaskPolicy `json:"task_policy" binding:"required"` // What happens to tasks in shared workspaces
+	Code       string            `json:"code"`                           // Required when two-factor login is on
+}
+
+// GetMe returns the caller's account
+func GetMe(c *gin.Context) {
+	resp := u.Message(true, "success")
+	resp["account"] = CurrentAccount(c)
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateMe changes the caller's profile; fields left out of the body keep their value
+func UpdateMe(c *gin.Context) {
+	var update models.ProfileUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	account := CurrentAccount(c)
+	if err := models.UpdateProfile(account, update); err != nil {
+		c.JSON(http.StatusBadRequest, u.Message(false, err.Error()))
+		return
+	}
+	resp := u.Message(true, "Profile updated")
+	resp["account"] = account
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteMe deletes the caller's account once they have confirmed it with their
+// password, and a two-factor code when that is on
+func DeleteMe(c *gin.Context) {
+	var request DeleteAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := request.TaskPolicy.IsValid(); err != nil {
+		c.JSON(http.StatusBadRequest, u.Message(false, err.Error()))
+		return
+	}
+
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("id = ?", CurrentAccount(c).ID).First(account).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Account not found"))
+		return
+	}
+	if !confirmPassword(c, account, request.Password, "Invalid login credentials. Please try again") {
+		return
+	}
+	if account.TwoFactorEnabled() && !confirmSecondFactor(c, account, request.Code) {
+		return
+	}
+
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		return models.DeleteAccount(tx, account.ID, request.TaskPolicy)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to delete account. Please retry"))
+		return
+	}
+	c.JSON(http.StatusOK, u.Message(true, "Account deleted"))
+}
+
+// GetSessions lists the caller's signed in sessions, marking the one making the request
+func GetSessions(c *gin.Context) {
+	sessions, err := models.ActiveSessions(CurrentAccount(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving sessions"})
+		return
+	}
+
+	current, _ := tokenClaims(c)["sid"].(string)
+	listed := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		listed = append(listed, gin.H{
+			"id":             session.ID,
+			"created_at":     session.CreatedAt,
+			"last_active_at": session.LastActiveAt,
+			"expires_at":     session.ExpiresAt,
+			"current":        session.ID == current,
+		})
+	}
+	c.JSON(http.StatusOK, listed)
+}
+
+// RevokeMySession signs out one of the caller's sessions
+func RevokeMySession(c *gin.Context) {
+	family := c.Param("id")
+	if !models.SessionBelongsTo(family, CurrentAccount(c).ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err := models.RevokeSession(family); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully!"})
+}
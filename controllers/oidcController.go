@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+	"task-management/oidc"
+	u "task-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie tying a sign in to the browser that started it. It holds a hash of the
+// state, so a callback carrying someone else's state is refused.
+const oidcStateCookie = "oidc_state"
+
+// GetOIDCProviders lists the providers users can sign in with
+func GetOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": oidc.Names()})
+}
+
+// OIDCLogin sends the user to the provider to sign in
+func OIDCLogin(c *gin.Context) {
+	target, ok := startOIDCLogin(c, nil)
+	if !ok {
+		return
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// LinkOIDCProvider starts linking a provider to the caller's account. The
+// response names the URL to send the user to; the callback completes the link
+// in the same browser.
+func LinkOIDCProvider(c *gin.Context) {
+	target, ok := startOIDCLogin(c, &CurrentAccount(c).ID)
+	if !ok {
+		return
+	}
+	resp := u.Message(true, "Sign in at the provider to link it")
+	resp["authorization_url"] = target
+	c.JSON(http.StatusOK, resp)
+}
+
+// startOIDCLogin records a sign in at the :provider and returns the provider's
+// authorization URL for it, writing the error response when it fails
+func startOIDCLogin(c *gin.Context, linkTo *uint) (string, bool) {
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, u.Message(false, "Unknown sign in provider"))
+		return "", false
+	}
+	nonce, verifier := models.RandomToken(16), oidc.NewVerifier()
+	state, err := models.StartOIDCLogin(provider.Name, linkTo, nonce, verifier)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to start sign in. Please retry"))
+		return "", false
+	}
+	target, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("oidc provider %s unavailable: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, u.Message(false, "Sign in provider is unavailable. Please retry"))
+		return "", false
+	}
+	// Lax, not Strict: the provider sends the browser back with a cross-site redirect
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, models.HashToken(state), int(models.OIDCLoginLifetime.Seconds()),
+		oidcCallbackPath(provider.Name), "", c.Request.TLS != nil, true)
+	return target, true
+}
+
+// oidcCallbackPath is where the :provider sends the user back to, the only
+// path the state cookie is sent to
+func oidcCallbackPath(provider string) string {
+	return "/auth/oidc/" + provider + "/callback"
+}
+
+// OIDCCallback completes a sign in or link when the provider sends the user back
+// with an authorization code. Sign ins answer like Login.
+func OIDCCallback(c *gin.Context) {
+	provider, ok := oidc.Lookup(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, u.Message(false, "Unknown sign in provider"))
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		msg := u.Message(false, "Sign in was not completed at the provider")
+		msg["error"] = reason
+		msg["error_description"] = c.Query("error_description")
+		c.JSON(http.StatusUnauthorized, msg)
+		return
+	}
+	bound, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCallbackPath(provider.Name), "", c.Request.TLS != nil, true)
+	if subtle.ConstantTimeCompare([]byte(bound), []byte(models.HashToken(c.Query("state")))) != 1 {
+		c.JSON(http.StatusBadRequest, u.Message(false, "Invalid or expired sign in attempt. Please start again"))
+		return
+	}
+	login, err := models.TakeOIDCLogin(provider.Name, c.Query("state"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, u.Message(false, "Invalid or expired sign in attempt. Please start again"))
+		return
+	}
+
+	tokens, err := provider.Exchange(c.Request.Context(), c.Query("code"), login.CodeVerifier)
+	if err != nil {
+		log.Printf("oidc provider %s code exchange failed: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Sign in with the provider failed"))
+		return
+	}
+	claims, err := provider.VerifyIDToken(c.Request.Context(), tokens.IDToken, login.Nonce)
+	if err != nil {
+		log.Printf("oidc provider %s sent an unacceptable ID token: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Sign in with the provider failed"))
+		return
+	}
+	identity := models.ExternalIdentity{
+		Provider:      provider.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}
+
+	if login.AccountID != nil {
+		linkIdentity(c, *login.AccountID, identity)
+		return
+	}
+
+	account, created, err := models.SignInWithIdentity(identity)
+	switch {
+	case errors.Is(err, models.ErrUnverifiedEmail):
+		c.JSON(http.StatusForbidden, u.Message(false, "The provider did not confirm your email address"))
+		return
+	case err != nil:
+		log.Printf("oidc sign in with %s failed: %v", provider.Name, err)
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to sign in. Please retry"))
+		return
+	}
+	if refuseSuspended(c, account) {
+		return
+	}
+	// Accounts with two-factor login still need their code
+	if account.TwoFactorEnabled() {
+		twoFactorChallenge(c, account)
+		return
+	}
+
+	session, err := issueTokens(account, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to sign in. Please retry"))
+		return
+	}
+	loginSucceeded(c, account)
+	resp := u.Message(true, "Logged In")
+	for key, value := range session {
+		resp[key] = value
+	}
+	resp["account_created"] = created
+	c.JSON(http.StatusOK, resp)
+}
+
+// linkIdentity finishes linking a provider to the account that started it
+func linkIdentity(c *gin.Context, accountID uint, identity models.ExternalIdentity) {
+	err := models.LinkIdentity(accountID, identity)
+	switch {
+	case errors.Is(err, models.ErrIdentityTaken):
+		c.JSON(http.StatusConflict, u.Message(false, "This identity is already linked to another account"))
+	case errors.Is(err, models.ErrProviderLinked):
+		c.JSON(http.StatusConflict, u.Message(false, "Your account is already linked to another identity at this provider"))
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to link account. Please retry"))
+	default:
+		c.JSON(http.StatusOK, u.Message(true, "Account linked"))
+	}
+}
+
+// GetIdentities lists the provider identities linked to the caller's account
+func GetIdentities(c *gin.Context) {
+	identities := []models.AccountIdentity{}
+	dao.GetDB().Where("account_id = ?", CurrentAccount(c).ID).Order("id").Find(&identities)
+	c.JSON(http.StatusOK, identities)
+}
+
+// UnlinkOIDCProvider removes the caller's identity at the :provider
+func UnlinkOIDCProvider(c *gin.Context) {
+	err := models.UnlinkIdentity(CurrentAccount(c).ID, c.Param("provider"))
+	if errors.Is(err, models.ErrIdentityNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully!"})
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"task-management/dao"
+	"task-management/mailer"
+	"task-management/models"
+	u "task-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPassword emails a reset token when the address belongs to an account.
+// The response is the same either way so it cannot be used to probe for accounts.
+func ForgotPassword(c *gin.Context) {
+	var request ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("email = ?", request.Email).First(account).Error; err == nil {
+		token, err := models.CreatePasswordReset(account.ID)
+		if err == nil {
+			err = mailer.Send(passwordResetMessage(account, token))
+		}
+		if err != nil {
+			log.Printf("password reset for account %d not sent: %v", account.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, u.Message(true, "If the address has an account, a reset link is on its way"))
+}
+
+func passwordResetMessage(account *models.Account, token string) mailer.Message {
+	return mailer.Message{
+		To:      account.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+			"POST this token with your new password to %s/password/reset:\n\n%s\n\n"+
+			"It can be used once and expires in %s. If it wasn't you, ignore this message.\n",
+			u.AppURL(), token, models.PasswordResetLifetime),
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and signs
+// the account out everywhere.
+func ResetPassword(c *gin.Context) {
+	var request ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if !validPassword(c, request.Password) {
+		return
+	}
+
+	err := models.ResetPassword(request.Token, request.Password)
+	if errors.Is(err, models.ErrInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, u.Message(false, "Invalid or expired reset token"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to reset password. Please retry"))
+		return
+	}
+	c.JSON(http.StatusOK, u.Message(true, "Password has been reset"))
+}
+
+// ChangePassword replaces the caller's password after checking the old one. Every
+// session is signed out; the response carries tokens for a fresh one.
+func ChangePassword(c *gin.Context) {
+	var request ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("id = ?", CurrentAccount(c).ID).First(account).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Account not found"))
+		return
+	}
+	if !confirmPassword(c, account, request.OldPassword, "Old password is incorrect") {
+		return
+	}
+	if !validPassword(c, request.NewPassword) {
+		return
+	}
+	if err := models.ChangePassword(account.ID, request.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to change password. Please retry"))
+		return
+	}
+
+	account.Password = ""
+	tokens, err := issueTokens(account, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Password changed, please log in again"))
+		return
+	}
+	resp := u.Message(true, "Password changed")
+	for key, value := range tokens {
+		resp[key] = value
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// validPassword applies the account password rules, writing a 400 when they fail
+func validPassword(c *gin.Context, password string) bool {
+	if len(password) < models.MinPasswordLength {
+		c.JSON(http.StatusBadRequest, u.Message(false, fmt.Sprintf("Password must be at least %d characters", models.MinPasswordLength)))
+		return false
+	}
+	if len(password) > models.MaxPasswordLength {
+		c.JSON(http.StatusBadRequest, u.Message(false, fmt.Sprintf("Password must be at most %d bytes", models.MaxPasswordLength)))
+		return false
+	}
+	return true
+}
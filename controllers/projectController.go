@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"task-management/dao"
+	"task-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetProjects(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	tx := dao.GetDB().Scopes(dao.InWorkspace(workspace)).Order("name")
+	if archived, _ := strconv.ParseBool(c.Query("archived")); !archived {
+		tx = tx.Where("archived_at IS NULL")
+	}
+	projects := []models.Project{}
+	tx.Find(&projects)
+	c.JSON(http.StatusOK, projects)
+}
+
+func GetProject(c *gin.Context) {
+	project, ok := findProject(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func CreateProject(c *gin.Context) {
+	account := CurrentAccount(c)
+	var project models.Project
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	project.ID = 0
+	project.AccountID = account.ID
+	project.WorkspaceID = CurrentWorkspaceID(c)
+	project.ArchivedAt = nil
+	if err := project.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project data", "details": err.Error()})
+		return
+	}
+
+	if projectNameTaken(&project) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project with this name already exists"})
+		return
+	}
+	if err := dao.GetDB().Create(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
+	}
+	c.JSON(http.StatusCreated, project)
+}
+
+func UpdateProject(c *gin.Context) {
+	project, ok := findProject(c)
+	if !ok {
+		return
+	}
+	projectID, accountID, workspaceID, archivedAt := project.ID, project.AccountID, project.WorkspaceID, project.ArchivedAt
+
+	if err := c.ShouldBindJSON(project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Archiving has its own endpoints
+	project.ID, project.AccountID, project.WorkspaceID, project.ArchivedAt = projectID, accountID, workspaceID, archivedAt
+	if err := project.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project data", "details": err.Error()})
+		return
+	}
+
+	if projectNameTaken(project) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project with this name already exists"})
+		return
+	}
+	if err := dao.GetDB().Save(project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func DeleteProject(c *gin.Context) {
+	project, ok := findProject(c)
+	if !ok {
+		return
+	}
+
+	var tasks int64
+	dao.GetDB().Model(&models.Task{}).Where("project_id = ?", project.ID).Count(&tasks)
+	if tasks > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project still has tasks; archive it or move its tasks first"})
+		return
+	}
+	if err := dao.GetDB().Delete(project).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error Deleting project!"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully!"})
+}
+
+func ArchiveProject(c *gin.Context) {
+	setProjectArchived(c, true)
+}
+
+func UnarchiveProject(c *gin.Context) {
+	setProjectArchived(c, false)
+}
+
+func setProjectArchived(c *gin.Context, archived bool) {
+	project, ok := findProject(c)
+	if !ok {
+		return
+	}
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+	if err := dao.GetDB().Model(project).Update("archived_at", archivedAt).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func GetProjectTasks(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	project, ok := findProject(c)
+	if !ok {
+		return
+	}
+	listTasks(c, dao.GetDB().Model(&models.Task{}).Scopes(dao.InWorkspace(workspace)).Where("project_id = ?", project.ID))
+}
+
+func CreateProjectTask(c *gin.Context) {
+	project, ok := findProject(c)
+	if !ok {
+		return
+	}
+	var task models.Task
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.ProjectID = &project.ID
+	createTask(c, &task)
+}
+
+// findProject loads the project of the caller's workspace named in the URL, writing a 404 when there is none
+func findProject(c *gin.Context) (*models.Project, bool) {
+	workspace := CurrentWorkspaceID(c)
+	project := &models.Project{}
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found!"})
+		return nil, false
+	}
+	return project, true
+}
+
+// findOpenProject loads a project of the caller's workspace that tasks can be added to,
+// writing the error response when there is none.
+func findOpenProject(c *gin.Context, projectID uint) (*models.Project, bool) {
+	workspace := CurrentWorkspaceID(c)
+	project := &models.Project{}
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(project, projectID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	if project.Archived() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project is archived"})
+		return nil, false
+	}
+	return project, true
+}
+
+// projectNameTaken reports whether another project of the same workspace has this name
+func projectNameTaken(project *models.Project) bool {
+	var count int64
+	dao.GetDB().Model(&models.Project{}).Scopes(dao.InWorkspace(project.WorkspaceID)).
+		Where("name = ? AND id <> ?", project.Name, project.ID).Count(&count)
+	return count > 0
+}
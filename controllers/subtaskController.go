@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MoveTaskRequest struct {
+	ParentID *uint `json:"parent_id"` // null makes the task top-level
+}
+
+func GetSubtree(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var task models.Task
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found!"})
+		return
+	}
+
+	tree, err := models.LoadSubtree(dao.InWorkspace(workspace), &task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load subtasks"})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// MoveTask re-parents a task, taking its whole subtree along
+func MoveTask(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var task models.Task
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var request MoveTaskRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkParent(c, request.ParentID) {
+		return
+	}
+	if err := models.CheckMove(&task, request.ParentID); err != nil {
+		if errors.Is(err, models.ErrTaskCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		}
+		return
+	}
+
+	oldParentID := task.ParentID
+	before, err := models.SnapshotTask(&task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+	err = dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Update("parent_id", request.ParentID).Error; err != nil {
+			return err
+		}
+		task.ParentID = request.ParentID
+		if err := models.RecordTaskActivity(tx, CurrentAccount(c), models.ActivityUpdated, &task, before); err != nil {
+			return err
+		}
+		// Either parent may now have nothing but completed subtasks
+		if err := models.RollupCompletion(tx, CurrentAccount(c), oldParentID); err != nil {
+			return err
+		}
+		return models.RollupCompletion(tx, CurrentAccount(c), request.ParentID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move task"})
+		return
+	}
+
+	dao.GetDB().First(&task, task.ID)
+	c.JSON(http.StatusOK, task)
+}
+
+// checkParent verifies that a requested parent task exists and belongs to the
+// caller, writing the error response when it does not.
+func checkParent(c *gin.Context, parentID *uint) bool {
+	if parentID == nil {
+		return true
+	}
+	workspace := CurrentWorkspaceID(c)
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&models.Task{}, *parentID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent task not found"})
+		return false
+	}
+	return true
+}
@@ -2,27 +2,52 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
 	"task-management/dao"
 	"task-management/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetAllTasks(c *gin.Context) {
-	var tasks []models.Task
-	dao.GetDB().Find(&tasks)
-	c.JSON(http.StatusOK, tasks)
+	workspace := CurrentWorkspaceID(c)
+	listTasks(c, dao.GetDB().Model(&models.Task{}).Scopes(dao.InWorkspace(workspace)))
 }
 
 func GetTaskByID(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
 	var task models.Task
-	if err := dao.GetDB().First(&task, c.Param("id")).Error; err != nil {
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).Preload("Labels").First(&task, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found!"})
 		return
 	}
 	c.JSON(http.StatusOK, task)
 }
 
+func SearchTasks(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query q is required"})
+		return
+	}
+
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := models.SearchTasks(dao.InWorkspace(workspace), text, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search tasks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
 func CreateTask(c *gin.Context) {
 	var task models.Task
 
@@ -32,16 +57,51 @@ func CreateTask(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	createTask(c, &task)
+}
+
+// createTask validates and stores a task bound from the request in the caller's workspace
+func createTask(c *gin.Context, task *models.Task) {
+	task.ID = 0
+	task.AccountID = CurrentAccount(c).ID
+	task.WorkspaceID = CurrentWorkspaceID(c)
+
+	// Validate the task
+	if err := task.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task data", "details": err.Error()})
+		return
+	}
+
+	if !checkParent(c, task.ParentID) {
+		return
+	}
+
+	// Tasks created in a project start in its default status
+	if task.ProjectID != nil {
+		project, ok := findOpenProject(c, *task.ProjectID)
+		if !ok {
+			return
+		}
+		if task.Status == "" {
+			task.Status = project.DefaultStatus
+		}
+	}
 
 	// Check if a task with the same title already exists
-	var existingTask models.Task
-	if err := dao.GetDB().Where("title = ?", task.Title).First(&existingTask).Error; err == nil {
+	if titleTaken(task) {
 		c.JSON(http.StatusConflict, gin.H{"error": "Task with this title already exists"})
 		return
 	}
 
-	// Create a new task
-	if err := dao.GetDB().Create(&task).Error; err != nil {
+	// Labels are attached through their own endpoints
+	task.Labels = nil
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(task).Error; err != nil {
+			return err
+		}
+		return models.RecordTaskActivity(tx, CurrentAccount(c), models.ActivityCreated, task, nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
 		return
 	}
@@ -50,17 +110,39 @@ func CreateTask(c *gin.Context) {
 	c.JSON(http.StatusCreated, task)
 }
 
+// titleTaken reports whether another task in the same project already has this
+// title. Tasks outside any project are compared with the workspace's other such tasks.
+func titleTaken(task *models.Task) bool {
+	tx := dao.GetDB().Model(&models.Task{}).Where("title = ? AND id <> ?", task.Title, task.ID)
+	if task.ProjectID != nil {
+		tx = tx.Where("project_id = ?", *task.ProjectID)
+	} else {
+		tx = tx.Where("project_id IS NULL").Scopes(dao.InWorkspace(task.WorkspaceID))
+	}
+	var count int64
+	tx.Count(&count)
+	return count > 0
+}
+
 func UpdateTask(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
 	var task models.Task
 
 	// Get task ID from URL parameters
 	id := c.Param("id")
 
 	// Find the task by ID
-	if err := dao.GetDB().First(&task, id).Error; err != nil {
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).Preload("Labels").First(&task, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		return
 	}
+	taskID, accountID, parentID, projectID, labels := task.ID, task.AccountID, task.ParentID, task.ProjectID, task.Labels
+	wasCompleted := task.Status == models.StatusCompleted
+	before, err := models.SnapshotTask(&task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+		return
+	}
 
 	// Bind the request body to the task model
 	if err := c.ShouldBindJSON(&task); err != nil {
@@ -68,14 +150,58 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
+	// The body may not move the task to another row, creator, workspace or parent,
+	// nor change its labels; those have their own endpoints
+	task.ID = taskID
+	task.AccountID = accountID
+	task.WorkspaceID = workspace
+	task.ParentID = parentID
+	task.Labels = labels
+
 	// Validate the task
 	if err := task.Validate(); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task data", "details": err.Error()})
 		return
 	}
 
-	// Update the task in the database
-	if err := dao.GetDB().Save(&task).Error; err != nil {
+	// Moving to another project needs a project that still takes tasks
+	if task.ProjectID != nil && (projectID == nil || *projectID != *task.ProjectID) {
+		if _, ok := findOpenProject(c, *task.ProjectID); !ok {
+			return
+		}
+	}
+	if titleTaken(&task) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Task with this title already exists"})
+		return
+	}
+
+	// A task cannot be completed while anything blocking it is still open
+	if task.Status == models.StatusCompleted && !wasCompleted {
+		blockers, err := models.Blockers(task.ID, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+			return
+		}
+		if len(blockers) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Task is blocked by open tasks", "blockers": blockers})
+			return
+		}
+	}
+
+	// Update the task in the database, recording what changed
+	err = dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&task).Error; err != nil {
+			return err
+		}
+		if err := models.RecordTaskActivity(tx, CurrentAccount(c), models.ActivityUpdated, &task, before); err != nil {
+			return err
+		}
+		if task.Status == models.StatusCompleted {
+			return models.RollupCompletion(tx, CurrentAccount(c), task.ParentID)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
 		return
 	}
@@ -85,13 +211,91 @@ func UpdateTask(c *gin.Context) {
 }
 
 func DeleteTask(c *gin.Context) {
-	rec := dao.GetDB().Delete(&models.Task{}, c.Param("id"))
-	if rec.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error Deleting task!"})
-		return
-	} else if rec.RowsAffected < 1 {
+	workspace := CurrentWorkspaceID(c)
+	var task models.Task
+	if err := dao.GetDB().Scopes(dao.InWorkspace(workspace)).First(&task, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found!"})
 		return
 	}
+
+	before, err := models.SnapshotTask(&task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error Deleting task!"})
+		return
+	}
+	// Subtasks move up to the deleted task's parent, dependencies on it go away
+	err = dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := models.DeleteTask(tx, CurrentAccount(c), &task); err != nil {
+			return err
+		}
+		return models.RecordTaskActivity(tx, CurrentAccount(c), models.ActivityDeleted, &task, before)
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error Deleting task!"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully!"})
 }
+
+type BulkPriorityRequest struct {
+	IDs      []uint              `json:"ids" binding:"required,min=1"`
+	Priority models.TaskPriority `json:"priority" binding:"required"`
+}
+
+func BulkUpdatePriority(c *gin.Context) {
+	workspace := CurrentWorkspaceID(c)
+	var request BulkPriorityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := request.Priority.IsValid(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every task must belong to the caller, otherwise nothing is changed
+	var tasks []models.Task
+	dao.GetDB().Scopes(dao.InWorkspace(workspace)).Where("id IN ?", request.IDs).Find(&tasks)
+	found := make([]uint, len(tasks))
+	owned := map[uint]bool{}
+	for i, task := range tasks {
+		found[i] = task.ID
+		owned[task.ID] = true
+	}
+	missing := []uint{}
+	for _, id := range request.IDs {
+		if !owned[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tasks not found", "ids": missing})
+		return
+	}
+
+	var updated int64
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		rec := tx.Model(&models.Task{}).Scopes(dao.InWorkspace(workspace)).Where("id IN ?", found).Update("priority", request.Priority)
+		if rec.Error != nil {
+			return rec.Error
+		}
+		updated = rec.RowsAffected
+		for i := range tasks {
+			before, err := models.SnapshotTask(&tasks[i])
+			if err != nil {
+				return err
+			}
+			tasks[i].Priority = request.Priority
+			if err := models.RecordTaskActivity(tx, CurrentAccount(c), models.ActivityUpdated, &tasks[i], before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tasks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tasks updated successfully!", "updated": updated})
+}
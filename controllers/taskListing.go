@@ -0,0 +1,441 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"task-management/models"
+	u "task-management/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Page sizes for task listings
+const (
+	defaultTaskPageSize = 20
+	maxTaskPageSize     = 100
+)
+
+// A column task listings can be sorted by. value reads the column back off a
+// task so it can be stored in a cursor, decode turns it back into a query argument.
+// Rows where a nullable column is NULL sort last in either direction.
+type taskSortColumn struct {
+	expr     string
+	value    func(task *models.Task) interface{}
+	decode   func(raw json.RawMessage) (interface{}, error)
+	nullable bool
+}
+
+// Columns accepted by the sort query parameter
+var taskSortColumns = map[string]taskSortColumn{
+	"id":         {"id", func(t *models.Task) interface{} { return t.ID }, decodeCursorValue[uint], false},
+	"title":      {"title", func(t *models.Task) interface{} { return t.Title }, decodeCursorValue[string], false},
+	"status":     {"status", func(t *models.Task) interface{} { return t.Status }, decodeCursorValue[string], false},
+	"created_at": {"created_at", func(t *models.Task) interface{} { return t.CreatedAt }, decodeCursorValue[time.Time], false},
+	"updated_at": {"updated_at", func(t *models.Task) interface{} { return t.UpdatedAt }, decodeCursorValue[time.Time], false},
+	"start_at":   {"start_at", func(t *models.Task) interface{} { return t.StartAt }, decodeCursorValue[time.Time], true},
+	"due_at":     {"due_at", func(t *models.Task) interface{} { return t.DueAt }, decodeCursorValue[time.Time], true},
+	"priority":   {priorityRankExpr(), func(t *models.Task) interface{} { return t.Priority.Rank() }, decodeCursorValue[int], false},
+}
+
+// priorityRankExpr ranks the priority column by urgency rather than alphabetically
+func priorityRankExpr() string {
+	expr := "CASE priority"
+	for rank, priority := range models.TaskPriorities {
+		expr += fmt.Sprintf(" WHEN '%s' THEN %d", priority, rank)
+	}
+	return expr + " END"
+}
+
+// nullsLast is the hidden key placed ahead of a nullable column to group its NULLs at the end
+func nullsLast(column taskSortColumn) taskSortColumn {
+	return taskSortColumn{
+		expr:   "(" + column.expr + " IS NULL)",
+		value:  func(t *models.Task) interface{} { return reflect.ValueOf(column.value(t)).IsNil() },
+		decode: decodeCursorValue[bool],
+	}
+}
+
+type taskSortKey struct {
+	name   string
+	column taskSortColumn
+	desc   bool
+}
+
+// Opaque position in a listing, handed to clients base64 encoded
+type taskCursor struct {
+	Sort   string            `json:"s"`
+	Prev   bool              `json:"p,omitempty"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// TaskPage is the envelope returned by task listings
+type TaskPage struct {
+	Data       []models.Task `json:"data"`
+	Total      int64         `json:"total"`
+	NextCursor *string       `json:"next_cursor"`
+	PrevCursor *string       `json:"prev_cursor"`
+}
+
+func decodeCursorValue[T any](raw json.RawMessage) (interface{}, error) {
+	var v T
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+// listTasks writes one page of the tasks selected by tx, applying the
+// filter, sort and cursor query parameters of the request.
+func listTasks(c *gin.Context, tx *gorm.DB) {
+	limit, err := pageSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err = filterTasks(c, tx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spec := c.DefaultQuery("sort", "id")
+	var cursor *taskCursor
+	if raw := c.Query("cursor"); raw != "" {
+		if cursor, err = decodeTaskCursor(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		// Cursors carry the normalized sort, with the id tie-breaker spelled out
+		if requested := c.Query("sort"); requested != "" {
+			keys, err := parseTaskSort(requested)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if sortSpec(keys) != cursor.Sort {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor was issued for a different sort"})
+				return
+			}
+		}
+		spec = cursor.Sort
+	}
+	keys, err := parseTaskSort(spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := tx.Session(&gorm.Session{})
+	var page TaskPage
+	if err := query.Count(&page.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tasks"})
+		return
+	}
+
+	backwards := cursor != nil && cursor.Prev
+	find := query
+	if cursor != nil {
+		values, err := cursorValues(keys, cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		where, args := keysetCondition(keys, values, backwards)
+		find = find.Where(where, args...)
+	}
+	for _, key := range keys {
+		direction := "ASC"
+		if key.desc != backwards {
+			direction = "DESC"
+		}
+		find = find.Order(key.column.expr + " " + direction)
+	}
+	if err := find.Preload("Labels").Limit(limit + 1).Find(&page.Data).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
+		return
+	}
+
+	more := len(page.Data) > limit
+	if more {
+		page.Data = page.Data[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(page.Data)-1; i < j; i, j = i+1, j-1 {
+			page.Data[i], page.Data[j] = page.Data[j], page.Data[i]
+		}
+	}
+	if n := len(page.Data); n > 0 {
+		// Walking forwards there is a previous page whenever we started from a cursor,
+		// walking backwards there is always a next page: the one we came from.
+		if (backwards && more) || (!backwards && cursor != nil) {
+			page.PrevCursor = encodeTaskCursor(keys, &page.Data[0], true)
+		}
+		if (!backwards && more) || backwards {
+			page.NextCursor = encodeTaskCursor(keys, &page.Data[n-1], false)
+		}
+	}
+	if page.Data == nil {
+		page.Data = []models.Task{}
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// pageSize reads the limit query parameter
+func pageSize(c *gin.Context) (int, error) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultTaskPageSize, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxTaskPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxTaskPageSize)
+	}
+	return n, nil
+}
+
+// filterTasks narrows tx with the status, priority, date range, title, parent,
+// project, label and deadline query parameters
+func filterTasks(c *gin.Context, tx *gorm.DB) (*gorm.DB, error) {
+	if raw := c.Query("status"); raw != "" {
+		var statuses []models.TaskStatus
+		for _, s := range strings.Split(raw, ",") {
+			status := models.TaskStatus(strings.TrimSpace(s))
+			if err := status.IsValid(); err != nil {
+				return nil, fmt.Errorf("invalid status %q", s)
+			}
+			statuses = append(statuses, status)
+		}
+		tx = tx.Where("status IN ?", statuses)
+	}
+
+	if raw := c.Query("priority"); raw != "" {
+		var priorities []models.TaskPriority
+		for _, p := range strings.Split(raw, ",") {
+			priority := models.TaskPriority(strings.TrimSpace(p))
+			if err := priority.IsValid(); err != nil {
+				return nil, fmt.Errorf("invalid priority %q", p)
+			}
+			priorities = append(priorities, priority)
+		}
+		tx = tx.Where("priority IN ?", priorities)
+	}
+
+	ranges := []struct{ param, condition string }{
+		{"created_after", "created_at >= ?"},
+		{"created_before", "created_at < ?"},
+		{"updated_after", "updated_at >= ?"},
+		{"updated_before", "updated_at < ?"},
+	}
+	for _, r := range ranges {
+		raw := c.Query(r.param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", r.param)
+		}
+		tx = tx.Where(r.condition, t)
+	}
+
+	if title := c.Query("title"); title != "" {
+		tx = tx.Where("LOWER(title) LIKE ? ESCAPE '\\'", "%"+u.EscapeLike(strings.ToLower(title))+"%")
+	}
+
+	for _, column := range []string{"parent_id", "project_id"} {
+		if raw := c.Query(column); raw == "none" {
+			tx = tx.Where(column + " IS NULL")
+		} else if raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an id or none", column)
+			}
+			tx = tx.Where(column+" = ?", id)
+		}
+	}
+
+	if raw := c.Query("label"); raw != "" {
+		names := map[string]bool{}
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names[name] = true
+			}
+		}
+		list := make([]string, 0, len(names))
+		for name := range names {
+			list = append(list, name)
+		}
+		labelled := "SELECT task_labels.task_id FROM task_labels JOIN labels ON labels.id = task_labels.label_id WHERE labels.name IN ?"
+		switch c.DefaultQuery("label_mode", "any") {
+		case "any":
+			tx = tx.Where("id IN ("+labelled+")", list)
+		case "all":
+			tx = tx.Where("id IN ("+labelled+" GROUP BY task_labels.task_id HAVING COUNT(DISTINCT labels.id) = ?)", list, len(list))
+		default:
+			return nil, errors.New("label_mode must be any or all")
+		}
+	}
+
+	now := time.Now().UTC()
+	if raw := c.Query("overdue"); raw != "" {
+		overdue, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("overdue must be true or false")
+		}
+		if overdue {
+			tx = tx.Where("due_at < ? AND status <> ?", now, models.StatusCompleted)
+		} else {
+			tx = tx.Where("(due_at IS NULL OR due_at >= ? OR status = ?)", now, models.StatusCompleted)
+		}
+	}
+	if raw := c.Query("due_within"); raw != "" {
+		window, err := parseWindow(raw)
+		if err != nil {
+			return nil, errors.New("due_within must be a duration such as 36h or 7d")
+		}
+		tx = tx.Where("due_at >= ? AND due_at <= ?", now, now.Add(window))
+	}
+	return tx, nil
+}
+
+// parseWindow parses a positive Go duration, or a whole number of days such as "7d"
+func parseWindow(raw string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	window, err := time.ParseDuration(raw)
+	if err == nil && window <= 0 {
+		err = errors.New("window must be positive")
+	}
+	return window, err
+}
+
+// parseTaskSort parses a sort spec such as "-created_at,title". The id column
+// is always appended as a tiebreaker so cursors identify a single row.
+func parseTaskSort(spec string) ([]taskSortKey, error) {
+	var keys []taskSortKey
+	seen := map[string]bool{}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		name := strings.TrimPrefix(field, "-")
+		column, ok := taskSortColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("sort field %q given twice", name)
+		}
+		seen[name] = true
+		if column.nullable {
+			keys = append(keys, taskSortKey{column: nullsLast(column)})
+		}
+		keys = append(keys, taskSortKey{name: name, column: column, desc: desc})
+	}
+	if !seen["id"] {
+		keys = append(keys, taskSortKey{name: "id", column: taskSortColumns["id"]})
+	}
+	return keys, nil
+}
+
+func sortSpec(keys []taskSortKey) string {
+	var fields []string
+	for _, key := range keys {
+		switch {
+		case key.name == "":
+			// Hidden keys are derived again when the spec is parsed
+		case key.desc:
+			fields = append(fields, "-"+key.name)
+		default:
+			fields = append(fields, key.name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// keysetCondition selects the rows strictly after values in the order given by
+// keys, or strictly before them when walking backwards. A nil value stands for
+// SQL NULL; nothing sorts strictly past a NULL within its own key.
+func keysetCondition(keys []taskSortKey, values []interface{}, backwards bool) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for i, key := range keys {
+		if values[i] == nil {
+			continue
+		}
+		var parts []string
+		var partArgs []interface{}
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				parts = append(parts, keys[j].column.expr+" IS NULL")
+			} else {
+				parts = append(parts, keys[j].column.expr+" = ?")
+				partArgs = append(partArgs, values[j])
+			}
+		}
+		op := ">"
+		if key.desc != backwards {
+			op = "<"
+		}
+		parts = append(parts, key.column.expr+" "+op+" ?")
+		partArgs = append(partArgs, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, partArgs...)
+	}
+	if len(clauses) == 0 {
+		return "1 = 0", nil
+	}
+	return strings.Join(clauses, " OR "), args
+}
+
+func encodeTaskCursor(keys []taskSortKey, task *models.Task, prev bool) *string {
+	cursor := taskCursor{Sort: sortSpec(keys), Prev: prev}
+	for _, key := range keys {
+		raw, _ := json.Marshal(key.column.value(task))
+		cursor.Values = append(cursor.Values, raw)
+	}
+	data, _ := json.Marshal(cursor)
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return &encoded
+}
+
+func decodeTaskCursor(raw string) (*taskCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &taskCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func cursorValues(keys []taskSortKey, cursor *taskCursor) ([]interface{}, error) {
+	if len(cursor.Values) != len(keys) {
+		return nil, errors.New("cursor does not match sort")
+	}
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if string(cursor.Values[i]) == "null" {
+			continue
+		}
+		v, err := key.column.decode(cursor.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
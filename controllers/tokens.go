@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"task-management/jwtkeys"
+	"task-management/models"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Claim naming what a single-purpose token (invitation, ...) may be used for.
+// Access tokens never carry it.
+const purposeClaim = "purpose"
+
+const (
+	invitationPurpose   = "invitation"
+	verificationPurpose = "email_verification"
+	twoFactorPurpose    = "two_factor_challenge"
+)
+
+// issueTokens signs a short-lived access token for account and stores the refresh
+// token that renews it. A non-empty family continues an existing session.
+func issueTokens(account *models.Account, family string) (map[string]interface{}, error) {
+	jti := models.RandomToken(16)
+	refresh, stored, err := models.IssueRefreshToken(account.ID, family, jti)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	access, err := signToken(jwt.MapClaims{
+		"username": account.Email,
+		"jti":      jti,
+		"sid":      stored.FamilyID, // Session the token belongs to, for logout
+		"iat":      now.Unix(),
+		"exp":      now.Add(models.AccessTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"token":         access,
+		"token_type":    "Bearer",
+		"expires_in":    int(models.AccessTokenLifetime.Seconds()),
+		"refresh_token": refresh,
+	}, nil
+}
+
+// signToken signs claims with the current signing key
+func signToken(claims jwt.MapClaims) (string, error) {
+	return jwtkeys.GetKeyring().Sign(claims)
+}
+
+// parseToken checks the signature and expiry of a token signed by signToken
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := jwtkeys.GetKeyring().Parse(tokenString)
+	if err != nil && acceptLegacyTokens() {
+		return parseLegacyToken(tokenString)
+	}
+	return claims, err
+}
+
+// Tokens used to be signed with the token_password HMAC secret. Setting
+// jwt_accept_hs256=true keeps accepting them while switching over; turn it off
+// once they have expired.
+func acceptLegacyTokens() bool {
+	return os.Getenv("jwt_accept_hs256") == "true" && len(jwtSecret) > 0
+}
+
+func parseLegacyToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// Inside the callback function checks if the token uses HMAC signing.
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// GetJWKS publishes the public keys tokens are verified with, so other services
+// can check them
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": jwtkeys.GetKeyring().JWKS()})
+}
+
+// parsePurposeToken parses a token that must have been issued for purpose
+func parsePurposeToken(tokenString, purpose string) (jwt.MapClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims[purposeClaim] != purpose {
+		return nil, fmt.Errorf("token is not a %s token", purpose)
+	}
+	return claims, nil
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"task-management/dao"
+	"task-management/models"
+	"task-management/totp"
+	u "task-management/utils"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// How long a password-checked login waits for its two-factor code
+const twoFactorChallengeLifetime = 5 * time.Minute
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// totpIssuer names the app in authenticator apps
+func totpIssuer() string {
+	if issuer := os.Getenv("totp_issuer"); issuer != "" {
+		return issuer
+	}
+	return "Task Management"
+}
+
+// EnrollTOTP starts two-factor enrolment. The secret is returned both raw, for
+// manual entry, and as an otpauth:// URI to render as a QR code.
+func EnrollTOTP(c *gin.Context) {
+	account := CurrentAccount(c)
+	secret, err := models.BeginTOTPEnrollment(account)
+	if errors.Is(err, models.ErrTwoFactorEnabled) {
+		c.JSON(http.StatusConflict, u.Message(false, "Two-factor login is already enabled"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to start two-factor enrolment. Please retry"))
+		return
+	}
+	resp := u.Message(true, "Add the secret to your authenticator app, then confirm with a code")
+	resp["secret"] = secret
+	resp["provisioning_uri"] = totp.ProvisioningURI(secret, totpIssuer(), account.Email)
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmTOTP enables two-factor login with a code from the enrolled app and
+// hands out the recovery codes
+func ConfirmTOTP(c *gin.Context) {
+	var request TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	codes, err := models.ConfirmTOTP(CurrentAccount(c), request.Code)
+	switch {
+	case errors.Is(err, models.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, u.Message(false, "Two-factor login is already enabled"))
+	case errors.Is(err, models.ErrTwoFactorNotStarted):
+		c.JSON(http.StatusBadRequest, u.Message(false, "Start two-factor enrolment first"))
+	case errors.Is(err, models.ErrInvalidTwoFactor):
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Invalid two-factor code"))
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to enable two-factor login. Please retry"))
+	default:
+		resp := u.Message(true, "Two-factor login enabled. Store the recovery codes somewhere safe")
+		resp["recovery_codes"] = codes
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// DisableTOTP turns two-factor login off; it takes both the password and a code
+func DisableTOTP(c *gin.Context) {
+	var request DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("id = ?", CurrentAccount(c).ID).First(account).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Account not found"))
+		return
+	}
+	if !account.TwoFactorEnabled() {
+		c.JSON(http.StatusBadRequest, u.Message(false, "Two-factor login is not enabled"))
+		return
+	}
+	if !confirmPassword(c, account, request.Password, "Invalid login credentials. Please try again") ||
+		!confirmSecondFactor(c, account, request.Code) {
+		return
+	}
+	if err := models.DisableTOTP(account.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to disable two-factor login. Please retry"))
+		return
+	}
+	c.JSON(http.StatusOK, u.Message(true, "Two-factor login disabled"))
+}
+
+// twoFactorChallenge answers a login whose first factor checked out with a
+// challenge to complete at /login/2fa
+func twoFactorChallenge(c *gin.Context, account *models.Account) {
+	challenge, err := signToken(jwt.MapClaims{
+		purposeClaim: twoFactorPurpose,
+		"account":    account.ID,
+		"exp":        time.Now().Add(twoFactorChallengeLifetime).Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to sign in. Please retry"))
+		return
+	}
+	resp := u.Message(true, "Two-factor code required")
+	resp["two_factor_required"] = true
+	resp["challenge_token"] = challenge
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// LoginTwoFactor finishes a login Login answered with a challenge
+func LoginTwoFactor(c *gin.Context) {
+	var request TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	claims, err := parsePurposeToken(request.ChallengeToken, twoFactorPurpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Invalid or expired challenge. Please log in again"))
+		return
+	}
+	id, _ := claims["account"].(float64)
+	account := &models.Account{}
+	if err := dao.GetDB().Table("accounts").Where("id = ?", uint(id)).First(account).Error; err != nil || !account.TwoFactorEnabled() {
+		c.JSON(http.StatusUnauthorized, u.Message(false, "Invalid or expired challenge. Please log in again"))
+		return
+	}
+	if !loginAllowed(c, account, account.Email) || refuseSuspended(c, account) {
+		return
+	}
+	if !confirmSecondFactor(c, account, request.Code) {
+		return
+	}
+	account.Password = ""
+
+	tokens, err := issueTokens(account, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, u.Message(false, "Failed to sign in. Please retry"))
+		return
+	}
+	resp := u.Message(true, "Logged In")
+	for key, value := range tokens {
+		resp[key] = value
+	}
+	loginSucceeded(c, account)
+	resp["recovery_codes_remaining"] = models.RemainingRecoveryCodes(account.ID)
+	c.JSON(http.StatusOK, resp)
+}
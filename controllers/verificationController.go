@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"task-management/dao"
+	"task-management/mailer"
+	"task-management/models"
+	u "task-management/utils"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// How long a verification link works
+const verificationLifetime = 48 * time.Hour
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// sendVerification emails account a signed link confirming its address
+func sendVerification(account *models.Account) error {
+	token, err := signToken(jwt.MapClaims{
+		purposeClaim: verificationPurpose,
+		"account":    account.ID,
+		"email":      account.Email,
+		"exp":        time.Now().Add(verificationLifetime).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return mailer.Send(mailer.Message{
+		To:      account.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Open this link to confirm the email address of your new account:\n\n%s/account/verify?token=%s\n\n"+
+			"The link expires in %s. If you did not sign up, ignore this message.\n",
+			u.AppURL(), url.QueryEscape(token), verificationLifetime),
+	})
+}
+
+// VerifyEmail confirms an address from the link sent by sendVerification
+func VerifyEmail(c *gin.Context) {
+	claims, err := parsePurposeToken(c.Query("token"), verificationPurpose)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, u.Message(false, "Invalid or expired verification link"))
+		return
+	}
+	id, _ := claims["account"].(float64)
+	email, _ := claims["email"].(string)
+	if err := models.MarkVerified(uint(id), email); err != nil {
+		c.JSON(http.StatusBadRequest, u.Message(false, "Invalid or expired verification link"))
+		return
+	}
+	c.JSON(http.StatusOK, u.Message(true, "Email address verified"))
+}
+
+// ResendVerification sends a new link to an unverified account. The response is
+// the same whether or not there is one.
+func ResendVerification(c *gin.Context) {
+	var request ResendVerificationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	account := &models.Account{}
+	err := dao.GetDB().Table("accounts").Where("email = ?", request.Email).First(account).Error
+	if err == nil && !account.Verified() {
+		if err := sendVerification(account); err != nil {
+			log.Printf("verification for account %d not sent: %v", account.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, u.Message(true, "If the address has an unverified account, a new link is on its way"))
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-management/dao"
+	"task-management/mailer"
+	"task-management/models"
+	u "task-management/utils"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+type InviteRequest struct {
+	Email string      `json:"email" binding:"required,email"`
+	Role  models.Role `json:"role"` // Defaults to member
+}
+
+type MemberRoleRequest struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+type InvitationResponseRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func GetWorkspaces(c *gin.Context) {
+	account := CurrentAccount(c)
+	workspaces := []models.Workspace{}
+	dao.GetDB().Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.account_id = ?", account.ID).Order("workspaces.id").Find(&workspaces)
+	c.JSON(http.StatusOK, gin.H{"data": workspaces, "active_workspace_id": account.ActiveWorkspaceID})
+}
+
+func GetWorkspace(c *gin.Context) {
+	workspace, ok := findWorkspace(c)
+	if !ok {
+		return
+	}
+	members, err := models.Members(workspace.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"workspace": workspace, "members": members})
+}
+
+func CreateWorkspace(c *gin.Context) {
+	var workspace models.Workspace
+	if err := c.ShouldBindJSON(&workspace); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workspace.ID = 0
+	workspace.Personal = false
+	if err := models.CreateWorkspace(&workspace, CurrentAccount(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workspace"})
+		return
+	}
+	c.JSON(http.StatusCreated, workspace)
+}
+
+// ActivateWorkspace switches the workspace the caller's requests act on
+func ActivateWorkspace(c *gin.Context) {
+	workspace, ok := findWorkspace(c)
+	if !ok {
+		return
+	}
+	account := CurrentAccount(c)
+	if err := dao.GetDB().Model(&models.Account{}).Where("id = ?", account.ID).Update("active_workspace_id", workspace.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, workspace)
+}
+
+// InviteMember emails a signed invitation to join the workspace
+func InviteMember(c *gin.Context) {
+	workspace, ok := findWorkspace(c)
+	if !ok {
+		return
+	}
+	account := CurrentAccount(c)
+	var request InviteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Role == "" {
+		request.Role = models.RoleMember
+	}
+	if err := request.Role.IsValid(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var members int64
+	dao.GetDB().Model(&models.WorkspaceMember{}).
+		Joins("JOIN accounts ON accounts.id = workspace_members.account_id").
+		Where("workspace_members.workspace_id = ? AND LOWER(accounts.email) = ?", workspace.ID, strings.ToLower(request.Email)).
+		Count(&members)
+	if members > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is already a member"})
+		return
+	}
+
+	invitation := models.Invitation{
+		WorkspaceID: workspace.ID,
+		Email:       request.Email,
+		InvitedByID: account.ID,
+		Role:        request.Role,
+		Status:      models.InvitationPending,
+		ExpiresAt:   time.Now().Add(models.InvitationLifetime),
+	}
+	if err := dao.GetDB().Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	token, err := signToken(jwt.MapClaims{
+		purposeClaim: invitationPurpose,
+		"invitation": invitation.ID,
+		"email":      invitation.Email,
+		"exp":        invitation.ExpiresAt.Unix(),
+	})
+	if err == nil {
+		err = mailer.Send(invitationMessage(workspace, account, &invitation, token))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation"})
+		return
+	}
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func invitationMessage(workspace *models.Workspace, inviter *models.Account, invitation *models.Invitation, token string) mailer.Message {
+	appURL := u.AppURL()
+	return mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to %s", workspace.Name),
+		Body: fmt.Sprintf("%s invited you to join the workspace %q.\n\n"+
+			"Sign in and POST this token to %s/invitations/accept to join, or to %s/invitations/decline to decline:\n\n%s\n\n"+
+			"The invitation expires on %s.\n",
+			inviter.Email, workspace.Name, appURL, appURL, token, invitation.ExpiresAt.UTC().Format(time.RFC1123)),
+	}
+}
+
+// GetInvitations lists the pending invitations addressed to the caller
+func GetInvitations(c *gin.Context) {
+	account := CurrentAccount(c)
+	invitations := []models.Invitation{}
+	dao.GetDB().Where("LOWER(email) = ? AND status = ? AND expires_at > ?", strings.ToLower(account.Email), models.InvitationPending, time.Now()).
+		Order("id").Find(&invitations)
+	c.JSON(http.StatusOK, invitations)
+}
+
+func AcceptInvitation(c *gin.Context) {
+	invitation, ok := findInvitation(c)
+	if !ok {
+		return
+	}
+	if err := models.AcceptInvitation(invitation, CurrentAccount(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+	c.JSON(http.StatusOK, invitation)
+}
+
+func DeclineInvitation(c *gin.Context) {
+	invitation, ok := findInvitation(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	invitation.Status, invitation.RespondedAt = models.InvitationDeclined, &now
+	if err := dao.GetDB().Save(invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline invitation"})
+		return
+	}
+	c.JSON(http.StatusOK, invitation)
+}
+
+// UpdateMemberRole changes the role of a member other than the owner
+func UpdateMemberRole(c *gin.Context) {
+	workspace, memberID, ok := findMember(c)
+	if !ok {
+		return
+	}
+	var request MemberRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if memberID == workspace.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The workspace owner's role cannot be changed"})
+		return
+	}
+
+	err := models.SetMemberRole(workspace.ID, memberID, request.Role)
+	if errors.Is(err, models.ErrInvalidRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrNotMember) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"workspace_id": workspace.ID, "account_id": memberID, "role": request.Role})
+}
+
+// RemoveMember takes a member out of the workspace. Admins can remove anyone but
+// the owner; other members can only leave.
+func RemoveMember(c *gin.Context) {
+	workspace, memberID, ok := findMember(c)
+	if !ok {
+		return
+	}
+	if memberID == workspace.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The workspace owner cannot be removed"})
+		return
+	}
+	if role := CurrentRole(c); memberID != CurrentAccount(c).ID && !role.Can(models.PermWorkspaceAdmin) {
+		forbidden(c, workspace.ID, role, models.PermWorkspaceAdmin)
+		return
+	}
+
+	err := models.RemoveMember(workspace.ID, memberID)
+	if errors.Is(err, models.ErrNotMember) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully!"})
+}
+
+// findWorkspace loads the workspace named in the URL, writing a 404 when there is
+// none. RequireOnWorkspace has already checked the caller is a member.
+func findWorkspace(c *gin.Context) (*models.Workspace, bool) {
+	workspace := &models.Workspace{}
+	if err := dao.GetDB().First(workspace, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+		return nil, false
+	}
+	return workspace, true
+}
+
+// findMember loads the workspace and parses the member account id named in the URL
+func findMember(c *gin.Context) (*models.Workspace, uint, bool) {
+	workspace, ok := findWorkspace(c)
+	if !ok {
+		return nil, 0, false
+	}
+	memberID, err := strconv.ParseUint(c.Param("accountId"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account id"})
+		return nil, 0, false
+	}
+	return workspace, uint(memberID), true
+}
+
+// findInvitation resolves the invitation token in the request body to a pending
+// invitation addressed to the caller, writing the error response when it is not.
+func findInvitation(c *gin.Context) (*models.Invitation, bool) {
+	var request InvitationResponseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	claims, err := parsePurposeToken(request.Token, invitationPurpose)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return nil, false
+	}
+
+	id, _ := claims["invitation"].(float64)
+	email, _ := claims["email"].(string)
+	invitation := &models.Invitation{}
+	if err := dao.GetDB().First(invitation, uint(id)).Error; err != nil || !invitation.InvitationFor(email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+		return nil, false
+	}
+	if !invitation.InvitationFor(CurrentAccount(c).Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invitation is for another account"})
+		return nil, false
+	}
+	if invitation.Status != models.InvitationPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invitation has already been " + string(invitation.Status)})
+		return nil, false
+	}
+	return invitation, true
+}
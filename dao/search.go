@@ -0,0 +1,68 @@
+package dao
+
+// How task search is served by the connected database
+type SearchBackend string
+
+const (
+	SearchLike     SearchBackend = "like"     // plain LIKE matching, ranked in Go
+	SearchFTS5     SearchBackend = "fts5"     // SQLite FTS5 virtual table
+	SearchPostgres SearchBackend = "postgres" // tsvector column with a GIN index
+)
+
+var searchBackend = SearchLike
+
+// SetupTaskSearch creates the full-text index over task titles and descriptions.
+// It must run after the tasks table has been migrated, for whichever database
+// is current. When the database cannot build an index (e.g. SQLite compiled
+// without FTS5) the error is returned and search keeps working through LIKE
+// matching.
+func SetupTaskSearch() error {
+	searchBackend = SearchLike
+	switch db.Dialector.Name() {
+	case "postgres":
+		statements := []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(description, '')), 'B')) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector)`,
+		}
+		if err := execAll(statements); err != nil {
+			return err
+		}
+		searchBackend = SearchPostgres
+	case "sqlite":
+		statements := []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(title, description, content='tasks', content_rowid='id')`,
+			`CREATE TRIGGER IF NOT EXISTS tasks_fts_insert AFTER INSERT ON tasks BEGIN
+				INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS tasks_fts_delete AFTER DELETE ON tasks BEGIN
+				INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS tasks_fts_update AFTER UPDATE ON tasks BEGIN
+				INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+				INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+			END`,
+			`INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild')`,
+		}
+		if err := execAll(statements); err != nil {
+			return err
+		}
+		searchBackend = SearchFTS5
+	}
+	return nil
+}
+
+// GetSearchBackend reports how task search is served by the current database
+func GetSearchBackend() SearchBackend {
+	return searchBackend
+}
+
+func execAll(statements []string) error {
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
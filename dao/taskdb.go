@@ -34,3 +34,10 @@ func GetDB() *gorm.DB {
 func SetDb(testdb *gorm.DB) {
 	db = testdb
 }
+
+// InWorkspace restricts a query to the rows belonging to the given workspace.
+func InWorkspace(workspaceID uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("workspace_id = ?", workspaceID)
+	}
+}
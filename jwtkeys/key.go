@@ -0,0 +1,188 @@
+// Package jwtkeys signs and verifies the app's JWTs with asymmetric keys, so
+// other services can verify tokens from the published key set without being
+// able to issue them. Keys are identified by the kid header and rotated; a
+// retired key keeps verifying until the tokens it signed have expired.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Algorithms keys can be generated for
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// A Key signs tokens under its ID. ExpiresAt is set once the key is retired.
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	RetiredAt *time.Time
+	ExpiresAt *time.Time
+	private   crypto.Signer
+}
+
+var ErrUnsupportedAlgorithm = errors.New("jwtkeys: unsupported algorithm")
+
+// Generate creates a new key for algorithm
+func Generate(algorithm string) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Key{ID: base64.RawURLEncoding.EncodeToString(id), Algorithm: algorithm, CreatedAt: time.Now(), private: private}, nil
+}
+
+// ParseKey restores a key from the PKCS #8 form of MarshalPrivate
+func ParseKey(id, algorithm string, der []byte) (*Key, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	key := &Key{ID: id, Algorithm: algorithm, private: private}
+	if _, err := key.signingKey(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// MarshalPrivate encodes the private key as PKCS #8
+func (k *Key) MarshalPrivate() ([]byte, error) {
+	return x509.MarshalPKCS8PrivateKey(k.private)
+}
+
+func (k *Key) Method() jwt.SigningMethod {
+	if k.Algorithm == EdDSA {
+		return SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// signingKey is the private key in the form k.Method() takes, checking the two agree
+func (k *Key) signingKey() (interface{}, error) {
+	switch private := k.private.(type) {
+	case *rsa.PrivateKey:
+		if k.Algorithm == RS256 {
+			return private, nil
+		}
+	case ed25519.PrivateKey:
+		if k.Algorithm == EdDSA {
+			return private, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s key of type %T", ErrUnsupportedAlgorithm, k.Algorithm, k.private)
+}
+
+func (k *Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// Expired reports whether the key was retired long enough ago that nothing it
+// signed is valid any more
+func (k *Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// JWK is the public half of a key as a JSON Web Key (RFC 7517, RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch public := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// Seal encrypts a marshalled private key for storage with a key derived from
+// secret. An empty secret leaves it as is.
+func Seal(der []byte, secret string) ([]byte, error) {
+	if secret == "" {
+		return der, nil
+	}
+	aead, err := sealer(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, der, nil), nil
+}
+
+// Open reverses Seal
+func Open(sealed []byte, secret string) ([]byte, error) {
+	if secret == "" {
+		return sealed, nil
+	}
+	aead, err := sealer(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("jwtkeys: sealed key is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func sealer(secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte("jwtkeys:" + secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,152 @@
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// An unknown kid reloads the keys at most this often, so another instance's
+// rotation is picked up without letting forged tokens hammer the store
+const refreshInterval = time.Minute
+
+var (
+	ErrNoSigningKey = errors.New("jwtkeys: no signing key")
+	ErrInvalidToken = errors.New("jwtkeys: invalid token")
+)
+
+// A Keyring holds the keys loaded from source. The newest key that has not been
+// retired signs; every key that has not expired verifies.
+type Keyring struct {
+	source func() ([]*Key, error)
+
+	mu          sync.RWMutex
+	keys        []*Key
+	refreshedAt time.Time
+}
+
+func NewKeyring(source func() ([]*Key, error)) *Keyring {
+	return &Keyring{source: source}
+}
+
+// Refresh reloads the keys from the keyring's source
+func (r *Keyring) Refresh() error {
+	keys, err := r.source()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys, r.refreshedAt = keys, time.Now()
+	return nil
+}
+
+// Current returns the key new tokens are signed with
+func (r *Keyring) Current() (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var current *Key
+	for _, key := range r.keys {
+		if key.RetiredAt == nil && (current == nil || key.CreatedAt.After(current.CreatedAt)) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, ErrNoSigningKey
+	}
+	return current, nil
+}
+
+// Lookup finds the unexpired key with id kid, reloading the keys once if it is unknown
+func (r *Keyring) Lookup(kid string) (*Key, bool) {
+	if key, ok := r.find(kid); ok {
+		return key, true
+	}
+	r.mu.RLock()
+	stale := time.Since(r.refreshedAt) >= refreshInterval
+	r.mu.RUnlock()
+	if !stale || r.Refresh() != nil {
+		return nil, false
+	}
+	return r.find(kid)
+}
+
+func (r *Keyring) find(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.ID == kid && !key.Expired(now) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS lists the public keys that verify tokens, for /.well-known/jwks.json
+func (r *Keyring) JWKS() []JWK {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now()
+	jwks := []JWK{}
+	for _, key := range r.keys {
+		if !key.Expired(now) {
+			jwks = append(jwks, key.JWK())
+		}
+	}
+	return jwks
+}
+
+// Sign signs claims with the current key, naming it in the kid header
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := r.Current()
+	if err != nil {
+		return "", err
+	}
+	private, err := key.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(private)
+}
+
+// Parse verifies a token signed by one of the keyring's keys, and its expiry
+func (r *Keyring) Parse(tokenString string) (jwt.MapClaims, error) {
+	parser := &jwt.Parser{ValidMethods: []string{RS256, EdDSA}}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := r.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// The key decides the algorithm, never the token
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("key %q does not sign %s", kid, token.Method.Alg())
+		}
+		return key.Public(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+var keyring = NewKeyring(func() ([]*Key, error) { return nil, nil })
+
+// GetKeyring returns the keyring the app signs its tokens with
+func GetKeyring() *Keyring {
+	return keyring
+}
+
+func SetKeyring(r *Keyring) {
+	keyring = r
+}
@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// A Mailer delivers messages to users
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// LogMailer writes messages to a file, or the standard log when Path is empty,
+// instead of delivering them. It is the default outside of production.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	entry := fmt.Sprintf("%s\nTo: %s\nSubject: %s\n\n%s\n", time.Now().UTC().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	if m.Path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, entry)
+	return err
+}
+
+// SMTPMailer delivers messages through an SMTP server, authenticating when
+// Username is set.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// ErrInvalidHeader is returned for addresses that would break out of their header
+var ErrInvalidHeader = errors.New("mailer: header value contains a line break")
+
+// Send refuses addresses with line breaks and encodes the subject, which may
+// carry user input such as a workspace name, so neither can add headers.
+func (m *SMTPMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(m.From, "\r\n") {
+		return ErrInvalidHeader
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.From, msg.To, mime.QEncoding.Encode("UTF-8", msg.Subject), strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{msg.To}, []byte(body))
+}
+
+// FromEnv picks the mailer configured in the environment: SMTP when smtp_host is
+// set, otherwise a LogMailer writing to mail_log_file (or the log).
+func FromEnv() Mailer {
+	if host := os.Getenv("smtp_host"); host != "" {
+		port := os.Getenv("smtp_port")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("smtp_user"),
+			Password: os.Getenv("smtp_password"),
+			From:     os.Getenv("mail_from"),
+		}
+	}
+	return &LogMailer{Path: os.Getenv("mail_log_file")}
+}
+
+var mailer Mailer = &LogMailer{}
+
+func GetMailer() Mailer {
+	return mailer
+}
+
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// Send delivers msg through the configured mailer
+func Send(msg Message) error {
+	return mailer.Send(msg)
+}
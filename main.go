@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"task-management/dao"
+	"task-management/jwtkeys"
+	"task-management/mailer"
 	"task-management/models"
+	"task-management/oidc"
 	"task-management/routes" // Assuming your SetupRoutes function is in this package
+	"task-management/storage"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -21,11 +29,113 @@ func main() {
 
 	// Apply migrations to create/update the database schema
 	// Run migrations
+	dao.GetDB().AutoMigrate(&models.Workspace{})
+	dao.GetDB().AutoMigrate(&models.WorkspaceMember{})
+	dao.GetDB().AutoMigrate(&models.Invitation{})
+	dao.GetDB().AutoMigrate(&models.Project{})
 	dao.GetDB().AutoMigrate(&models.Task{})
-	dao.GetDB().AutoMigrate(&models.Account{})
+	// Task titles used to be unique across the whole table, now only within a project
+	if migrator := dao.GetDB().Migrator(); migrator.HasConstraint(&models.Task{}, "uni_tasks_title") {
+		migrator.DropConstraint(&models.Task{}, "uni_tasks_title")
+	}
+	// Accounts from before email verification existed count as verified
+	verifyExisting := !dao.GetDB().Migrator().HasColumn(&models.Account{}, "verified_at")
+	// Sign-up used to let an email be registered twice; such pairs block the unique index until merged
+	if err := dao.GetDB().AutoMigrate(&models.Account{}); err != nil {
+		log.Printf("accounts not migrated: %v", err)
+	}
+	if verifyExisting {
+		dao.GetDB().Model(&models.Account{}).Where("verified_at IS NULL").Update("verified_at", time.Now())
+	}
+	dao.GetDB().AutoMigrate(&models.RefreshToken{})
+	dao.GetDB().AutoMigrate(&models.RevokedToken{})
+	dao.GetDB().AutoMigrate(&models.PasswordReset{})
+	dao.GetDB().AutoMigrate(&models.PersonalAccessToken{})
+	dao.GetDB().AutoMigrate(&models.RecoveryCode{})
+	dao.GetDB().AutoMigrate(&models.LoginThrottle{})
+	dao.GetDB().AutoMigrate(&models.AuthEvent{})
+	dao.GetDB().AutoMigrate(&models.AccountIdentity{})
+	dao.GetDB().AutoMigrate(&models.OIDCLogin{})
+	dao.GetDB().AutoMigrate(&models.SigningKey{})
+	dao.GetDB().AutoMigrate(&models.AuditLog{})
+	dao.GetDB().AutoMigrate(&models.Comment{})
+	dao.GetDB().AutoMigrate(&models.CommentRevision{})
+	dao.GetDB().AutoMigrate(&models.Attachment{})
+	dao.GetDB().AutoMigrate(&models.OrphanedBlob{})
+	dao.GetDB().AutoMigrate(&models.Activity{})
+	dao.GetDB().AutoMigrate(&models.Label{})
+	dao.GetDB().AutoMigrate(&models.TaskDependency{})
+	if err := dao.SetupTaskSearch(); err != nil {
+		log.Printf("full-text index unavailable, falling back to LIKE search: %v", err)
+	}
+
+	// Tokens are signed with keys from the database, rotated every jwt_key_rotation
+	algorithm := os.Getenv("jwt_algorithm")
+	if algorithm == "" {
+		algorithm = jwtkeys.RS256
+	}
+	rotateEvery := 30 * 24 * time.Hour
+	if raw := os.Getenv("jwt_key_rotation"); raw != "" {
+		every, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("invalid jwt_key_rotation: %v", err)
+		}
+		rotateEvery = every
+	}
+	if err := models.InitSigningKeys(algorithm, rotateEvery); err != nil {
+		log.Fatalf("could not set up signing keys: %v", err)
+	}
+	go func() {
+		// Other instances' rotations are picked up here too
+		for range time.Tick(time.Hour) {
+			if _, err := models.RotateSigningKeyIfDue(algorithm, rotateEvery); err != nil {
+				log.Printf("signing key rotation failed: %v", err)
+			}
+			if err := jwtkeys.GetKeyring().Refresh(); err != nil {
+				log.Printf("signing keys not reloaded: %v", err)
+			}
+		}
+	}()
+
+	// Outgoing mail goes through SMTP when configured, otherwise to a log
+	mailer.SetMailer(mailer.FromEnv())
+	// Attachments are kept on disk under blob_dir, or in the S3 bucket of blob_store=s3
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid blob store: %v", err)
+	}
+	storage.SetStore(store)
+	go func() {
+		// Removes the blobs attachments no longer point at, once past their grace period
+		for range time.Tick(models.BlobSweepGrace) {
+			if err := models.SweepBlobs(context.Background(), store, models.BlobSweepGrace); err != nil {
+				log.Printf("blob sweep failed: %v", err)
+			}
+		}
+	}()
+	// Single sign-on providers listed in oidc_providers
+	for _, provider := range oidc.FromEnv() {
+		oidc.Register(provider)
+	}
+
+	// Accounts listed in admin_emails administer the site
+	if emails := os.Getenv("admin_emails"); emails != "" {
+		if err := models.GrantAdmin(strings.Split(emails, ",")); err != nil {
+			log.Printf("could not grant admin: %v", err)
+		}
+	}
 
 	// Initialize the Gin router
 	router := gin.Default()
+	// Login throttling goes by client address; only proxies listed in
+	// trusted_proxies may set it through X-Forwarded-For
+	var proxies []string
+	if raw := os.Getenv("trusted_proxies"); raw != "" {
+		proxies = strings.Split(raw, ",")
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("invalid trusted_proxies: %v", err)
+	}
 
 	// Set up routes for the API
 	routes.SetupRoutes(router)
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management/dao"
+	"time"
+)
+
+// Personal access tokens start with this so they can be told apart from JWTs
+// (and spotted by secret scanners)
+const PersonalAccessTokenPrefix = "tm_pat_"
+
+// What a personal access token may be used for
+type TokenScope string
+
+const (
+	ScopeTasksRead  TokenScope = "tasks:read"
+	ScopeTasksWrite TokenScope = "tasks:write"
+)
+
+func (scope TokenScope) IsValid() error {
+	switch scope {
+	case ScopeTasksRead, ScopeTasksWrite:
+		return nil
+	}
+	return fmt.Errorf("invalid scope %q, must be one of: tasks:read, tasks:write", scope)
+}
+
+// Scope returns the token scope a permission needs, or "" when tokens can never have it
+func (permission Permission) Scope() TokenScope {
+	switch permission {
+	case PermTaskRead, PermLabelRead, PermProjectRead, PermWorkspaceRead:
+		return ScopeTasksRead
+	case PermTaskWrite, PermCommentWrite, PermLabelWrite:
+		return ScopeTasksWrite
+	}
+	return ""
+}
+
+// TokenScopes is stored as a comma separated list
+type TokenScopes []TokenScope
+
+func (scopes TokenScopes) Has(scope TokenScope) bool {
+	for _, granted := range scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (scopes TokenScopes) Value() (driver.Value, error) {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ","), nil
+}
+
+func (scopes *TokenScopes) Scan(value interface{}) error {
+	var joined string
+	switch v := value.(type) {
+	case string:
+		joined = v
+	case []byte:
+		joined = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into TokenScopes", value)
+	}
+	*scopes = TokenScopes{}
+	for _, name := range strings.Split(joined, ",") {
+		if name != "" {
+			*scopes = append(*scopes, TokenScope(name))
+		}
+	}
+	return nil
+}
+
+// A named, long-lived token an account mints for automation. Only a hash of the
+// token is stored; Token is filled in once, when it is created.
+type PersonalAccessToken struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	AccountID  uint        `gorm:"index;not null" json:"account_id"`
+	Name       string      `gorm:"not null" json:"name" binding:"required,min=1,max=100"`
+	Scopes     TokenScopes `gorm:"type:text;not null" json:"scopes" binding:"required,min=1"`
+	TokenHash  string      `gorm:"uniqueIndex;not null" json:"-"`
+	Hint       string      `json:"hint"` // Last characters of the token, to tell tokens apart
+	ExpiresAt  *time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Token      string      `gorm:"-" json:"token,omitempty"`
+}
+
+var ErrInvalidAccessToken = errors.New("invalid or expired access token")
+
+// Validate checks the token fields that binding tags cannot
+func (pat *PersonalAccessToken) Validate() error {
+	for _, scope := range pat.Scopes {
+		if err := scope.IsValid(); err != nil {
+			return err
+		}
+	}
+	if pat.ExpiresAt != nil && !pat.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+	return nil
+}
+
+// CreatePersonalAccessToken generates the token for pat and stores its hash
+func CreatePersonalAccessToken(pat *PersonalAccessToken) error {
+	pat.Token = PersonalAccessTokenPrefix + RandomToken(32)
+	pat.TokenHash = HashToken(pat.Token)
+	pat.Hint = pat.Token[len(pat.Token)-4:]
+	return dao.GetDB().Create(pat).Error
+}
+
+// UsePersonalAccessToken looks up a live token and records that it was used
+func UsePersonalAccessToken(raw string) (*PersonalAccessToken, error) {
+	pat := &PersonalAccessToken{}
+	if err := dao.GetDB().Where("token_hash = ?", HashToken(raw)).First(pat).Error; err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	now := time.Now()
+	if pat.ExpiresAt != nil && now.After(*pat.ExpiresAt) {
+		return nil, ErrInvalidAccessToken
+	}
+	pat.LastUsedAt = &now
+	dao.GetDB().Model(pat).UpdateColumn("last_used_at", now)
+	return pat, nil
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TaskPolicy decides what happens to the tasks an account created in shared
+// workspaces when the account is deleted
+type TaskPolicy string
+
+const (
+	TaskPolicyDelete   TaskPolicy = "delete"   // The tasks are deleted with the account
+	TaskPolicyReassign TaskPolicy = "reassign" // The tasks pass to each workspace's owner
+)
+
+func (p TaskPolicy) IsValid() error {
+	if p != TaskPolicyDelete && p != TaskPolicyReassign {
+		return fmt.Errorf("invalid task_policy: %s, must be one of [%s %s]", p, TaskPolicyDelete, TaskPolicyReassign)
+	}
+	return nil
+}
+
+// DeleteAccount deletes an account and everything only it can reach. Workspaces
+// nobody else belongs to are deleted whole; a shared workspace it owned passes
+// to its longest-standing admin, or else its longest-standing member. In shared
+// workspaces its tasks are handled by policy, and its labels and projects pass
+// to the owner.
+func DeleteAccount(tx *gorm.DB, accountID uint, policy TaskPolicy) error {
+	if err := policy.IsValid(); err != nil {
+		return err
+	}
+	// Signs out the account's sessions, including access tokens still in flight
+	if err := revokeSessions(tx, "account_id = ?", accountID); err != nil {
+		return err
+	}
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		var memberships []WorkspaceMember
+		if err := tx.Where("account_id = ?", accountID).Find(&memberships).Error; err != nil {
+			return err
+		}
+		for _, membership := range memberships {
+			if err := leaveWorkspace(tx, membership, policy); err != nil {
+				return err
+			}
+		}
+
+		for _, model := range []interface{}{
+			&RefreshToken{}, &PersonalAccessToken{}, &RecoveryCode{}, &PasswordReset{}, &AccountIdentity{}, &OIDCLogin{},
+		} {
+			if err := tx.Where("account_id = ?", accountID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("throttle_key = ?", AccountThrottleKey(accountID)).Delete(&LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		// The row holds the email and password hash, so it must never be soft deleted
+		return tx.Unscoped().Delete(&Account{}, accountID).Error
+	})
+}
+
+// leaveWorkspace takes a departing account out of a workspace, deleting the
+// workspace if nobody else is left in it
+func leaveWorkspace(tx *gorm.DB, membership WorkspaceMember, policy TaskPolicy) error {
+	workspace := &Workspace{}
+	if err := tx.First(workspace, membership.WorkspaceID).Error; err != nil {
+		return err
+	}
+
+	successor := &WorkspaceMember{}
+	err := tx.Where("workspace_id = ? AND account_id <> ?", workspace.ID, membership.AccountID).
+		Order(fmt.Sprintf("CASE WHEN role = '%s' THEN 0 WHEN role = '%s' THEN 1 ELSE 2 END", RoleAdmin, RoleMember)).
+		Order("created_at, account_id").
+		Limit(1).Find(successor).Error
+	if err != nil {
+		return err
+	}
+	if successor.AccountID == 0 {
+		return deleteWorkspace(tx, workspace.ID)
+	}
+
+	ownerID := workspace.OwnerID
+	if ownerID == membership.AccountID {
+		ownerID = successor.AccountID
+		err := tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND account_id = ?", workspace.ID, ownerID).
+			Update("role", RoleOwner).Error
+		if err == nil {
+			// A personal workspace stops being one once it changes hands
+			err = tx.Model(workspace).Updates(map[string]interface{}{"owner_id": ownerID, "personal": false}).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := handleTasks(tx, workspace.ID, membership.AccountID, ownerID, policy); err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&Label{}, &Project{}} {
+		err := tx.Model(model).Where("workspace_id = ? AND account_id = ?", workspace.ID, membership.AccountID).
+			Update("account_id", ownerID).Error
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Model(&Invitation{}).Where("workspace_id = ? AND invited_by_id = ?", workspace.ID, membership.AccountID).
+		Update("invited_by_id", ownerID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("workspace_id = ? AND account_id = ?", workspace.ID, membership.AccountID).Delete(&WorkspaceMember{}).Error
+}
+
+// handleTasks deletes or reassigns to ownerID the tasks accountID created in a workspace
+func handleTasks(tx *gorm.DB, workspaceID, accountID, ownerID uint, policy TaskPolicy) error {
+	query := tx.Model(&Task{}).Where("workspace_id = ? AND account_id = ?", workspaceID, accountID)
+	if policy == TaskPolicyReassign {
+		return query.Update("account_id", ownerID).Error
+	}
+
+	// The deletions show in the workspace's activity as the departing account's
+	actor := &Account{}
+	if err := tx.Select("id", "email").First(actor, accountID).Error; err != nil {
+		return err
+	}
+	var tasks []Task
+	if err := query.Find(&tasks).Error; err != nil {
+		return err
+	}
+	for i := range tasks {
+		// Reload the parent, an earlier deletion may have re-parented the task
+		if err := tx.Select("parent_id").First(&tasks[i], tasks[i].ID).Error; err != nil {
+			return err
+		}
+		before, err := SnapshotTask(&tasks[i])
+		if err != nil {
+			return err
+		}
+		if err := DeleteTask(tx, actor, &tasks[i]); err != nil {
+			return err
+		}
+		if err := RecordTaskActivity(tx, actor, ActivityDeleted, &tasks[i], before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteWorkspace deletes a workspace with everything in it
+func deleteWorkspace(tx *gorm.DB, workspaceID uint) error {
+	tasks := tx.Model(&Task{}).Select("id").Where("workspace_id = ?", workspaceID)
+	err := tx.Where("task_id IN (?) OR blocker_id IN (?)", tasks, tasks).Delete(&TaskDependency{}).Error
+	if err == nil {
+		err = tx.Exec("DELETE FROM task_labels WHERE task_id IN (?)", tasks).Error
+	}
+	if err == nil {
+		err = deleteTaskComments(tx, tasks)
+	}
+	if err == nil {
+		err = deleteTaskAttachments(tx, tasks)
+	}
+	if err == nil {
+		err = deleteWorkspaceActivity(tx, workspaceID)
+	}
+	for _, model := range []interface{}{&Task{}, &Label{}, &Project{}, &Invitation{}, &WorkspaceMember{}} {
+		if err != nil {
+			return err
+		}
+		err = tx.Where("workspace_id = ?", workspaceID).Delete(model).Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Delete(&Workspace{}, workspaceID).Error
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"task-management/dao"
 	u "task-management/utils"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
@@ -14,10 +15,28 @@ import (
 // a struct to rep user account
 type Account struct {
 	gorm.Model
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email             string `gorm:"uniqueIndex" json:"email"`
+	Password          string `json:"password,omitempty"`
+	DisplayName       string `json:"display_name"`
+	Timezone          string `json:"timezone"` // IANA name, e.g. Europe/Paris; empty means UTC
+	AvatarURL         string `json:"avatar_url"`
+	ActiveWorkspaceID *uint  `json:"active_workspace_id"` // Workspace the account's requests act on
+	// Tokens issued before the password last changed are no longer accepted
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	VerifiedAt        *time.Time `json:"verified_at"` // Set once the email address is confirmed
+	// Two-factor login; the secret is set during enrolment and only used once enabled
+	TOTPSecret    string     `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at"`
+	TOTPLastStep  int64      `gorm:"column:totp_last_step" json:"-"`         // Codes at or before this step were used
+	IsAdmin       bool       `gorm:"not null;default:false" json:"is_admin"` // Site administrator, see GrantAdmin
+	SuspendedAt   *time.Time `json:"suspended_at"`                           // Suspended accounts cannot sign in or use their tokens
 }
 
+const MinPasswordLength = 6
+
+// bcrypt refuses passwords longer than this many bytes
+const MaxPasswordLength = 72
+
 // Validate incoming user details...
 func (account *Account) Validate() (map[string]interface{}, bool) {
 
@@ -25,9 +44,12 @@ func (account *Account) Validate() (map[string]interface{}, bool) {
 		return u.Message(false, "Email address is required"), false
 	}
 
-	if len(account.Password) < 6 {
+	if len(account.Password) < MinPasswordLength {
 		return u.Message(false, "Password is required"), false
 	}
+	if len(account.Password) > MaxPasswordLength {
+		return u.Message(false, fmt.Sprintf("Password must be at most %d bytes", MaxPasswordLength)), false
+	}
 
 	//Email must be unique
 	temp := &Account{}
@@ -46,8 +68,8 @@ func (account *Account) Validate() (map[string]interface{}, bool) {
 			return u.Message(false, "Requirement passed"), true
 		}
 	} else {
-		// Continue with execution if a record is found
-		return u.Message(false, "Requirement passed"), true
+		// Accounts are looked up by email, so a second one would take over the first's sign-ins
+		return u.Message(false, "Email address already in use by another user"), false
 	}
 }
 
@@ -57,8 +79,14 @@ func (account *Account) Create() map[string]interface{} {
 		return resp
 	}
 
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(account.Password), bcrypt.DefaultCost)
-	account.Password = string(hashedPassword)
+	// Sign-up decides none of the account's state beyond its credentials
+	account.ActiveWorkspaceID, account.PasswordChangedAt, account.VerifiedAt = nil, nil, nil
+	account.TOTPSecret, account.TOTPEnabledAt, account.TOTPLastStep = "", nil, 0
+	account.IsAdmin, account.SuspendedAt = false, nil
+	if err := account.ValidateProfile(); err != nil {
+		return u.Message(false, err.Error())
+	}
+	account.SetPassword(account.Password)
 
 	dao.GetDB().Create(account)
 
@@ -66,11 +94,59 @@ func (account *Account) Create() map[string]interface{} {
 		return u.Message(false, "Failed to create account, connection error.")
 	}
 
+	account.Password = "" // Not even the hash leaves the server
 	response := u.Message(true, "Account has been created")
 	response["account"] = account
 	return response
 }
 
+func (account *Account) Verified() bool {
+	return account.VerifiedAt != nil
+}
+
+var ErrAccountNotFound = errors.New("account not found")
+
+// MarkVerified records that the account's owner receives mail at email. It fails
+// with ErrAccountNotFound once the account no longer has that address.
+func MarkVerified(accountID uint, email string) error {
+	account := &Account{}
+	if err := dao.GetDB().Table("accounts").Where("id = ? AND email = ?", accountID, email).First(account).Error; err != nil {
+		return ErrAccountNotFound
+	}
+	if account.Verified() {
+		return nil
+	}
+	return dao.GetDB().Model(&Account{}).Where("id = ?", accountID).Update("verified_at", time.Now()).Error
+}
+
+// SetPassword stores the bcrypt hash of password on the account, without saving it
+func (account *Account) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	account.Password = string(hashedPassword)
+	return nil
+}
+
+// CheckPassword reports whether password matches the account's stored hash
+func (account *Account) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)) == nil
+}
+
+// ChangePassword saves a new password and signs the account out of every session
+func ChangePassword(accountID uint, password string) error {
+	return changePassword(dao.GetDB(), accountID, password)
+}
+
+// GrantAdmin makes the accounts with the given emails administrators
+func GrantAdmin(emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+	return dao.GetDB().Model(&Account{}).Where("email IN ?", emails).Update("is_admin", true).Error
+}
+
 func GetUser(u uint) *Account {
 
 	acc := &Account{}
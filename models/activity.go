@@ -0,0 +1,155 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ActivityAction string
+
+const (
+	ActivityCreated ActivityAction = "created"
+	ActivityUpdated ActivityAction = "updated"
+	ActivityDeleted ActivityAction = "deleted"
+)
+
+// TrackedTaskFields are the fields of a task its history follows, named as in
+// its JSON
+var TrackedTaskFields = []string{"title", "description", "status", "priority", "project_id", "parent_id", "rollup_completion", "start_at", "due_at"}
+
+var ErrActivityImmutable = errors.New("activity entries cannot be changed")
+
+// One field of a task before and after a change, as JSON. Before is null for
+// created tasks and After for deleted ones.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// FieldChanges is stored as JSON
+type FieldChanges []FieldChange
+
+func (changes FieldChanges) Value() (driver.Value, error) {
+	encoded, err := json.Marshal(changes)
+	return string(encoded), err
+}
+
+func (changes *FieldChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), changes)
+	case []byte:
+		return json.Unmarshal(v, changes)
+	case nil:
+		*changes = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into FieldChanges", value)
+	}
+}
+
+// An entry of a task's history: who created, changed or deleted it, and what
+// each tracked field was before and after. Entries are written in the same
+// transaction as the change and never updated or deleted afterwards, except
+// along with their workspace. The title and actor's email are copied in so the
+// entry still reads once the task or account is gone.
+type Activity struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint           `gorm:"index;not null" json:"workspace_id"`
+	TaskID      uint           `gorm:"index;not null" json:"task_id"`
+	TaskTitle   string         `json:"task_title"`
+	ActorID     uint           `gorm:"index;not null" json:"actor_id"`
+	ActorEmail  string         `json:"actor_email"`
+	Action      ActivityAction `gorm:"index;not null" json:"action"`
+	Changes     FieldChanges   `gorm:"type:text;not null" json:"changes"`
+	CreatedAt   time.Time      `gorm:"index" json:"created_at"`
+}
+
+func (activity *Activity) BeforeUpdate(tx *gorm.DB) error {
+	return ErrActivityImmutable
+}
+
+func (activity *Activity) BeforeDelete(tx *gorm.DB) error {
+	return ErrActivityImmutable
+}
+
+// TaskSnapshot holds a task's tracked fields as JSON. Take one before changing
+// a task, its pointer fields may be overwritten in place.
+type TaskSnapshot map[string]json.RawMessage
+
+func SnapshotTask(task *Task) (TaskSnapshot, error) {
+	encoded, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	var fields TaskSnapshot
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	snapshot := TaskSnapshot{}
+	for _, field := range TrackedTaskFields {
+		snapshot[field] = fields[field]
+	}
+	return snapshot, nil
+}
+
+// Diff lists the tracked fields that differ between two snapshots. A nil
+// snapshot stands for a task that does not exist, all of its fields null.
+func (before TaskSnapshot) Diff(after TaskSnapshot) FieldChanges {
+	null := json.RawMessage("null")
+	value := func(snapshot TaskSnapshot, field string) json.RawMessage {
+		if v, ok := snapshot[field]; ok && v != nil {
+			return v
+		}
+		return null
+	}
+
+	changes := FieldChanges{}
+	for _, field := range TrackedTaskFields {
+		was, is := value(before, field), value(after, field)
+		if !bytes.Equal(was, is) {
+			changes = append(changes, FieldChange{Field: field, Before: was, After: is})
+		}
+	}
+	return changes
+}
+
+// RecordTaskActivity appends an entry for what actor did to task within tx, the
+// transaction doing it. before is the snapshot taken beforehand, nil when the
+// task was just created. Updates that changed no tracked field are not recorded.
+func RecordTaskActivity(tx *gorm.DB, actor *Account, action ActivityAction, task *Task, before TaskSnapshot) error {
+	var after TaskSnapshot
+	if action != ActivityDeleted {
+		var err error
+		if after, err = SnapshotTask(task); err != nil {
+			return err
+		}
+	}
+	changes := before.Diff(after)
+	if action == ActivityUpdated && len(changes) == 0 {
+		return nil
+	}
+
+	return tx.Create(&Activity{
+		WorkspaceID: task.WorkspaceID,
+		TaskID:      task.ID,
+		TaskTitle:   task.Title,
+		ActorID:     actor.ID,
+		ActorEmail:  actor.Email,
+		Action:      action,
+		Changes:     changes,
+	}).Error
+}
+
+// deleteWorkspaceActivity removes a deleted workspace's history. It bypasses
+// the hooks that keep entries from being deleted otherwise.
+func deleteWorkspaceActivity(tx *gorm.DB, workspaceID uint) error {
+	return tx.Exec("DELETE FROM activities WHERE workspace_id = ?", workspaceID).Error
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// An account as listed to administrators
+type AccountSummary struct {
+	ID          uint       `json:"id"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"display_name"`
+	IsAdmin     bool       `json:"is_admin"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	TaskCount   int64      `json:"task_count"` // Tasks the account created, in any workspace
+}
+
+// AccountSummaryColumns selects an AccountSummary from the accounts table
+const AccountSummaryColumns = "accounts.id, accounts.email, accounts.display_name, accounts.is_admin, accounts.verified_at, " +
+	"accounts.suspended_at, accounts.created_at, (SELECT COUNT(*) FROM tasks WHERE tasks.account_id = accounts.id) AS task_count"
+
+func (account *Account) Suspended() bool {
+	return account.SuspendedAt != nil
+}
+
+// SuspendAccount stops an account from signing in and signs it out everywhere.
+// Its data stays as it is until it is reactivated or deleted.
+func SuspendAccount(tx *gorm.DB, accountID uint) error {
+	err := tx.Model(&Account{}).Where("id = ? AND suspended_at IS NULL", accountID).Update("suspended_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return revokeSessions(tx, "account_id = ?", accountID)
+}
+
+// ReactivateAccount lifts a suspension
+func ReactivateAccount(tx *gorm.DB, accountID uint) error {
+	return tx.Model(&Account{}).Where("id = ?", accountID).Update("suspended_at", nil).Error
+}
+
+// ForcePasswordReset replaces an account's password with one nobody knows,
+// signs it out everywhere and returns a reset token for its owner to choose a
+// new one with
+func ForcePasswordReset(tx *gorm.DB, accountID uint) (string, error) {
+	if err := changePassword(tx, accountID, RandomToken(32)); err != nil {
+		return "", err
+	}
+	return createPasswordReset(tx, accountID)
+}
+
+// TaskCounts counts the tasks an account created by status
+func TaskCounts(accountID uint) (map[TaskStatus]int64, error) {
+	var rows []struct {
+		Status TaskStatus
+		Count  int64
+	}
+	err := dao.GetDB().Model(&Task{}).Select("status, COUNT(*) AS count").
+		Where("account_id = ?", accountID).Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := map[TaskStatus]int64{}
+	for _, status := range TaskStatuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
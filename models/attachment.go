@@ -0,0 +1,156 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"task-management/dao"
+	"task-management/storage"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A file attached to a task. Contents live in the blob store under their
+// SHA-256, so attachments with the same contents share one blob.
+type Attachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TaskID      uint      `gorm:"index;not null" json:"task_id"`
+	AccountID   uint      `gorm:"index;not null" json:"account_id"` // Uploader
+	Filename    string    `gorm:"not null" json:"filename"`
+	ContentType string    `gorm:"not null" json:"content_type"` // As detected from the contents, not as uploaded
+	Size        int64     `gorm:"not null" json:"size"`
+	SHA256      string    `gorm:"column:sha256;index;not null" json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// A blob that may have lost its last attachment, or is being stored for a new
+// one. The row is locked while either happens, and SweepBlobs only removes
+// blobs whose row is older than the grace period.
+type OrphanedBlob struct {
+	SHA256    string `gorm:"column:sha256;primaryKey"`
+	CreatedAt time.Time
+}
+
+// BlobSweepGrace is how long a blob stays orphaned before SweepBlobs removes it
+const BlobSweepGrace = 15 * time.Minute
+
+var ErrAttachmentEmpty = errors.New("attachment is empty")
+
+// BlobKey is where the blob with the given hex SHA-256 is kept
+func BlobKey(sum string) string {
+	return "attachments/" + sum[:2] + "/" + sum
+}
+
+// CreateAttachment records the attachment and stores content, unless another
+// attachment already has the same hash
+func CreateAttachment(ctx context.Context, store storage.BlobStore, attachment *Attachment, content []byte) error {
+	if len(content) == 0 {
+		return ErrAttachmentEmpty
+	}
+	sum := sha256.Sum256(content)
+	attachment.SHA256 = hex.EncodeToString(sum[:])
+	attachment.Size = int64(len(content))
+
+	// Marking the blob restarts its grace period, and its lock waits out a sweep
+	// already deciding on it. Should the upload fail, the sweep cleans up after it.
+	if err := orphanBlobs(dao.GetDB(), []string{attachment.SHA256}); err != nil {
+		return err
+	}
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&OrphanedBlob{}, "sha256 = ?", attachment.SHA256).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(attachment).Error; err != nil {
+			return err
+		}
+		var shared int64
+		if err := tx.Model(&Attachment{}).Where("sha256 = ? AND id <> ?", attachment.SHA256, attachment.ID).Count(&shared).Error; err != nil {
+			return err
+		}
+		if shared > 0 {
+			return nil
+		}
+		return store.Put(ctx, BlobKey(attachment.SHA256), bytes.NewReader(content), attachment.Size, attachment.ContentType)
+	})
+}
+
+// DeleteAttachment removes the attachment, leaving its blob to SweepBlobs
+func DeleteAttachment(attachment *Attachment) error {
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := orphanBlobs(tx, []string{attachment.SHA256}); err != nil {
+			return err
+		}
+		return tx.Delete(attachment).Error
+	})
+}
+
+// deleteTaskAttachments removes the attachments of the tasks selected by
+// taskIDs, leaving their blobs to SweepBlobs
+func deleteTaskAttachments(tx *gorm.DB, taskIDs interface{}) error {
+	var sums []string
+	if err := tx.Model(&Attachment{}).Distinct().Where("task_id IN (?)", taskIDs).Pluck("sha256", &sums).Error; err != nil {
+		return err
+	}
+	if err := orphanBlobs(tx, sums); err != nil {
+		return err
+	}
+	return tx.Where("task_id IN (?)", taskIDs).Delete(&Attachment{}).Error
+}
+
+// orphanBlobs marks blobs for SweepBlobs to check, restarting their grace period
+func orphanBlobs(tx *gorm.DB, sums []string) error {
+	if len(sums) == 0 {
+		return nil
+	}
+	orphans := make([]OrphanedBlob, len(sums))
+	for i, sum := range sums {
+		orphans[i] = OrphanedBlob{SHA256: sum}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sha256"}},
+		DoUpdates: clause.AssignmentColumns([]string{"created_at"}),
+	}).Create(&orphans).Error
+}
+
+// SweepBlobs removes the blobs orphaned for longer than grace that no
+// attachment points at any more. Blobs that fail to delete stay orphaned and
+// are retried on the next sweep.
+func SweepBlobs(ctx context.Context, store storage.BlobStore, grace time.Duration) error {
+	cutoff := time.Now().Add(-grace)
+	var sums []string
+	if err := dao.GetDB().Model(&OrphanedBlob{}).Where("created_at <= ?", cutoff).Pluck("sha256", &sums).Error; err != nil {
+		return err
+	}
+	for _, sum := range sums {
+		err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+			// Uploads of the same contents wait on this lock, and refresh the
+			// row first, so a blob is never removed from under a new attachment
+			var orphan OrphanedBlob
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sha256 = ? AND created_at <= ?", sum, cutoff).Take(&orphan).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			var shared int64
+			if err := tx.Model(&Attachment{}).Where("sha256 = ?", sum).Count(&shared).Error; err != nil {
+				return err
+			}
+			if shared == 0 {
+				if err := store.Delete(ctx, BlobKey(sum)); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&orphan).Error
+		})
+		if err != nil {
+			log.Printf("could not sweep blob %s: %v", sum, err)
+		}
+	}
+	return nil
+}
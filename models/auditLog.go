@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AuditAction string
+
+const (
+	AuditAccountSuspended     AuditAction = "account_suspended"
+	AuditAccountReactivated   AuditAction = "account_reactivated"
+	AuditAccountDeleted       AuditAction = "account_deleted"
+	AuditAccountUnlocked      AuditAction = "account_unlocked"
+	AuditPasswordResetForced  AuditAction = "password_reset_forced"
+	AuditImpersonationStarted AuditAction = "impersonation_started"
+	AuditImpersonatedRequest  AuditAction = "impersonated_request" // A request made through an impersonation token
+	AuditAccountsListed       AuditAction = "accounts_listed"
+	AuditAccountViewed        AuditAction = "account_viewed"
+	AuditAuthEventsViewed     AuditAction = "auth_events_viewed"
+	AuditAuditLogViewed       AuditAction = "audit_log_viewed"
+)
+
+// A record of something an administrator did. The target's email is copied in
+// so the entry still reads after the account is deleted.
+type AuditLog struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	ActorID     uint        `gorm:"index;not null" json:"actor_id"`
+	ActorEmail  string      `json:"actor_email"`
+	Action      AuditAction `gorm:"index;not null" json:"action"`
+	TargetID    uint        `gorm:"index" json:"target_id"` // The account acted on; zero for reads across accounts
+	TargetEmail string      `json:"target_email"`
+	Detail      string      `json:"detail,omitempty"`
+	IP          string      `json:"ip"`
+	CreatedAt   time.Time   `gorm:"index" json:"created_at"`
+}
+
+// RecordAudit appends entry to the audit trail. A change records its entry in
+// the transaction making it, so neither is kept without the other.
+func RecordAudit(tx *gorm.DB, entry *AuditLog) error {
+	return tx.Create(entry).Error
+}
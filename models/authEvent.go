@@ -0,0 +1,37 @@
+package models
+
+import (
+	"log"
+	"task-management/dao"
+	"time"
+)
+
+type AuthEventType string
+
+const (
+	EventLoginSucceeded  AuthEventType = "login_succeeded"
+	EventLoginFailed     AuthEventType = "login_failed"
+	EventLoginThrottled  AuthEventType = "login_throttled" // Attempt refused without checking the password
+	EventAccountLocked   AuthEventType = "account_locked"
+	EventAccountUnlocked AuthEventType = "account_unlocked"
+)
+
+// A record of a sign-in attempt or a change to an account's lockout. AccountID
+// is nil when the email did not match an account.
+type AuthEvent struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	Type      AuthEventType `gorm:"index;not null" json:"type"`
+	AccountID *uint         `gorm:"index" json:"account_id"`
+	Email     string        `json:"email"`
+	IP        string        `gorm:"index" json:"ip"`
+	Detail    string        `json:"detail,omitempty"`
+	CreatedAt time.Time     `gorm:"index" json:"created_at"`
+}
+
+// RecordAuthEvent appends event to the log. Failing to log never fails the
+// request, so errors only go to the server log.
+func RecordAuthEvent(event AuthEvent) {
+	if err := dao.GetDB().Create(&event).Error; err != nil {
+		log.Printf("auth event %s not recorded: %v", event.Type, err)
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const MaxCommentLength = 10000
+
+// A comment on a task. The body is markdown, stored as written and rendered by
+// clients. Comments thread one level deep: a reply's ParentID is a top-level
+// comment of the same task. Deleting a comment is soft, so replies keep their
+// context; a deleted comment is listed without its body while it has replies.
+type Comment struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	TaskID    uint           `gorm:"index;not null" json:"task_id"`
+	AccountID uint           `gorm:"index;not null" json:"account_id"` // Author
+	ParentID  *uint          `gorm:"index" json:"parent_id"`
+	Body      string         `gorm:"type:text;not null" json:"body"`
+	EditedAt  *time.Time     `json:"edited_at"` // Set when the body last changed, see CommentRevision
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	Author  *CommentAuthor `gorm:"-" json:"author"`            // Nil once the author's account is deleted
+	Replies []Comment      `gorm:"-" json:"replies,omitempty"` // Only on top-level comments
+}
+
+// A body a comment had before it was edited
+type CommentRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CommentID uint      `gorm:"index;not null" json:"comment_id"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `json:"created_at"` // When it was replaced
+}
+
+// The author of a comment as shown next to it
+type CommentAuthor struct {
+	ID          uint   `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+var (
+	ErrInvalidCommentParent = errors.New("parent_id must be a top-level comment of the same task")
+	ErrInvalidCommentBody   = errors.New("body must be between 1 and 10000 characters")
+)
+
+func (comment *Comment) AfterFind(tx *gorm.DB) error {
+	if comment.DeletedAt.Valid {
+		comment.Body = ""
+	}
+	return nil
+}
+
+func validCommentBody(body string) bool {
+	return len([]rune(body)) <= MaxCommentLength && len(body) > 0
+}
+
+// CreateComment stores comment, checking the comment it replies to
+func CreateComment(comment *Comment) error {
+	if !validCommentBody(comment.Body) {
+		return ErrInvalidCommentBody
+	}
+	if comment.ParentID != nil {
+		parent := &Comment{}
+		err := dao.GetDB().Where("id = ? AND task_id = ? AND parent_id IS NULL", *comment.ParentID, comment.TaskID).First(parent).Error
+		if err != nil {
+			return ErrInvalidCommentParent
+		}
+	}
+	return dao.GetDB().Create(comment).Error
+}
+
+// EditComment replaces the body of comment, keeping the old one as a revision
+func EditComment(comment *Comment, body string) error {
+	if !validCommentBody(body) {
+		return ErrInvalidCommentBody
+	}
+	if body == comment.Body {
+		return nil
+	}
+	now := time.Now()
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&CommentRevision{CommentID: comment.ID, Body: comment.Body, CreatedAt: now}).Error; err != nil {
+			return err
+		}
+		comment.Body, comment.EditedAt = body, &now
+		return tx.Model(comment).Updates(map[string]interface{}{"body": body, "edited_at": now}).Error
+	})
+}
+
+// CommentRevisions lists the earlier bodies of a comment, newest first
+func CommentRevisions(commentID uint) ([]CommentRevision, error) {
+	revisions := []CommentRevision{}
+	err := dao.GetDB().Where("comment_id = ?", commentID).Order("id DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// TopLevelComments selects a task's top-level comments, oldest first. Deleted
+// ones are kept while they have replies.
+func TopLevelComments(taskID uint) *gorm.DB {
+	live := dao.GetDB().Model(&Comment{}).Select("parent_id").Where("task_id = ? AND parent_id IS NOT NULL", taskID)
+	return dao.GetDB().Unscoped().Model(&Comment{}).
+		Where("task_id = ? AND parent_id IS NULL", taskID).
+		Where("deleted_at IS NULL OR id IN (?)", live)
+}
+
+// LoadThreads fills in the replies of top-level comments and the authors of all of them
+func LoadThreads(comments []Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	var replies []Comment
+	if err := dao.GetDB().Where("parent_id IN ?", ids).Order("id").Find(&replies).Error; err != nil {
+		return err
+	}
+
+	authorIDs := map[uint]bool{}
+	for _, comment := range comments {
+		authorIDs[comment.AccountID] = true
+	}
+	for _, reply := range replies {
+		authorIDs[reply.AccountID] = true
+	}
+	authors, err := commentAuthors(authorIDs)
+	if err != nil {
+		return err
+	}
+
+	byParent := map[uint][]Comment{}
+	for _, reply := range replies {
+		reply.Author = authors[reply.AccountID]
+		byParent[*reply.ParentID] = append(byParent[*reply.ParentID], reply)
+	}
+	for i := range comments {
+		comments[i].Author = authors[comments[i].AccountID]
+		comments[i].Replies = byParent[comments[i].ID]
+	}
+	return nil
+}
+
+func commentAuthors(ids map[uint]bool) (map[uint]*CommentAuthor, error) {
+	list := make([]uint, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	var found []CommentAuthor
+	err := dao.GetDB().Model(&Account{}).Select("id, email, display_name, avatar_url").Where("id IN ?", list).Scan(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	authors := map[uint]*CommentAuthor{}
+	for i := range found {
+		authors[found[i].ID] = &found[i]
+	}
+	return authors, nil
+}
+
+// deleteTaskComments removes the comments of the tasks selected by taskIDs for good
+func deleteTaskComments(tx *gorm.DB, taskIDs interface{}) error {
+	comments := tx.Unscoped().Model(&Comment{}).Select("id").Where("task_id IN (?)", taskIDs)
+	if err := tx.Where("comment_id IN (?)", comments).Delete(&CommentRevision{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("task_id IN (?)", taskIDs).Delete(&Comment{}).Error
+}
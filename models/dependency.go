@@ -0,0 +1,156 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// TaskDependency records that TaskID cannot be completed before BlockerID
+type TaskDependency struct {
+	TaskID    uint      `gorm:"primaryKey" json:"task_id"`
+	BlockerID uint      `gorm:"primaryKey;index" json:"blocker_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// The dependencies among a set of tasks
+type DependencyGraph struct {
+	Nodes []Task           `json:"nodes"`
+	Edges []TaskDependency `json:"edges"`
+}
+
+// Blockers returns the tasks blocking taskID; with open set, only those not yet completed
+func Blockers(taskID uint, open bool) ([]Task, error) {
+	tx := dao.GetDB().Where("id IN (?)", dao.GetDB().Model(&TaskDependency{}).Select("blocker_id").Where("task_id = ?", taskID))
+	if open {
+		tx = tx.Where("status <> ?", StatusCompleted)
+	}
+	blockers := []Task{}
+	err := tx.Order("id").Find(&blockers).Error
+	return blockers, err
+}
+
+// Blocking returns the tasks that taskID blocks
+func Blocking(taskID uint) ([]Task, error) {
+	blocked := []Task{}
+	err := dao.GetDB().Where("id IN (?)", dao.GetDB().Model(&TaskDependency{}).Select("task_id").Where("blocker_id = ?", taskID)).
+		Order("id").Find(&blocked).Error
+	return blocked, err
+}
+
+// AddDependency makes dependency.BlockerID block dependency.TaskID, tasks of
+// workspaceID, unless that would close a loop. Additions to a workspace take
+// turns on its row: checked apart, two of them could each close half a cycle.
+func AddDependency(workspaceID uint, dependency *TaskDependency) error {
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&Workspace{}, workspaceID).Error; err != nil {
+			return err
+		}
+		if err := checkDependency(tx, dependency.TaskID, dependency.BlockerID); err != nil {
+			return err
+		}
+		return tx.FirstOrCreate(dependency, *dependency).Error
+	})
+}
+
+// checkDependency returns ErrDependencyCycle if making blockerID block taskID
+// would close a loop, i.e. blockerID already waits on taskID directly or transitively.
+func checkDependency(tx *gorm.DB, taskID, blockerID uint) error {
+	if taskID == blockerID {
+		return ErrDependencyCycle
+	}
+	seen := map[uint]bool{blockerID: true}
+	frontier := []uint{blockerID}
+	for len(frontier) > 0 {
+		var next []uint
+		if err := tx.Model(&TaskDependency{}).Where("task_id IN ?", frontier).Pluck("blocker_id", &next).Error; err != nil {
+			return err
+		}
+		frontier = frontier[:0]
+		for _, id := range next {
+			if id == taskID {
+				return ErrDependencyCycle
+			}
+			if !seen[id] {
+				seen[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadDependencyGraph returns every dependency between tasks visible through
+// scope, together with the tasks taking part in one.
+func LoadDependencyGraph(scope func(*gorm.DB) *gorm.DB) (*DependencyGraph, error) {
+	visible := dao.GetDB().Model(&Task{}).Scopes(scope).Select("id")
+	graph := &DependencyGraph{Nodes: []Task{}, Edges: []TaskDependency{}}
+	err := dao.GetDB().Where("task_id IN (?) AND blocker_id IN (?)", visible, visible).
+		Order("task_id, blocker_id").Find(&graph.Edges).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[uint]bool{}
+	for _, edge := range graph.Edges {
+		ids[edge.TaskID] = true
+		ids[edge.BlockerID] = true
+	}
+	if len(ids) > 0 {
+		list := make([]uint, 0, len(ids))
+		for id := range ids {
+			list = append(list, id)
+		}
+		if err := dao.GetDB().Where("id IN ?", list).Order("id").Find(&graph.Nodes).Error; err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+// ExecutionOrder sorts the graph's tasks so every blocker comes before the
+// tasks it blocks. Among tasks that are ready together the most urgent goes first.
+func (graph *DependencyGraph) ExecutionOrder() ([]Task, error) {
+	waiting := map[uint]int{}
+	unblocks := map[uint][]uint{}
+	for _, edge := range graph.Edges {
+		waiting[edge.TaskID]++
+		unblocks[edge.BlockerID] = append(unblocks[edge.BlockerID], edge.TaskID)
+	}
+	byID := map[uint]Task{}
+	var ready []Task
+	for _, task := range graph.Nodes {
+		byID[task.ID] = task
+		if waiting[task.ID] == 0 {
+			ready = append(ready, task)
+		}
+	}
+
+	order := []Task{}
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool {
+			if ready[i].Priority.Rank() != ready[j].Priority.Rank() {
+				return ready[i].Priority.Rank() > ready[j].Priority.Rank()
+			}
+			return ready[i].ID < ready[j].ID
+		})
+		task := ready[0]
+		ready = ready[1:]
+		order = append(order, task)
+		for _, id := range unblocks[task.ID] {
+			if waiting[id]--; waiting[id] == 0 {
+				ready = append(ready, byID[id])
+			}
+		}
+	}
+	if len(order) != len(graph.Nodes) {
+		return nil, ErrDependencyCycle
+	}
+	return order, nil
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How long a user has to finish signing in at a provider
+const OIDCLoginLifetime = 10 * time.Minute
+
+// An account's identity at an OpenID Connect provider. An account has at most
+// one identity per provider.
+type AccountIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	AccountID   uint       `gorm:"not null;uniqueIndex:idx_identities_account_provider" json:"account_id"`
+	Provider    string     `gorm:"not null;uniqueIndex:idx_identities_account_provider;uniqueIndex:idx_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"not null;uniqueIndex:idx_identities_provider_subject" json:"subject"`
+	Email       string     `json:"email"` // As last reported by the provider
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// A sign in started at a provider and not finished yet. The state handed to the
+// provider is stored hashed; the nonce and PKCE verifier check its response.
+type OIDCLogin struct {
+	StateHash    string    `gorm:"primaryKey"`
+	Provider     string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	AccountID    *uint     // Set when a signed in account is linking the provider
+	ExpiresAt    time.Time `gorm:"index"`
+}
+
+// ExternalIdentity is who a provider says signed in
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+var (
+	ErrInvalidOIDCState   = errors.New("invalid or expired sign in attempt")
+	ErrUnverifiedEmail    = errors.New("provider did not confirm the email address")
+	ErrIdentityTaken      = errors.New("identity is linked to another account")
+	ErrProviderLinked     = errors.New("account is already linked to another identity at this provider")
+	ErrIdentityNotFound   = errors.New("identity not found")
+	errIdentityNotCreated = errors.New("account could not be created")
+)
+
+// StartOIDCLogin records a sign in at provider, or a link of the provider to
+// accountID when it is not nil. The returned state identifies it in the callback.
+func StartOIDCLogin(provider string, accountID *uint, nonce, verifier string) (string, error) {
+	state := RandomToken(32)
+	login := &OIDCLogin{
+		StateHash:    HashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		AccountID:    accountID,
+		ExpiresAt:    time.Now().Add(OIDCLoginLifetime),
+	}
+	return state, dao.GetDB().Create(login).Error
+}
+
+// TakeOIDCLogin returns the sign in identified by state and forgets it, so a
+// callback can only be completed once.
+func TakeOIDCLogin(provider, state string) (*OIDCLogin, error) {
+	login := &OIDCLogin{}
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ? AND provider = ? AND expires_at > ?", HashToken(state), provider, time.Now()).Take(login).Error
+		if err != nil {
+			return ErrInvalidOIDCState
+		}
+		rec := tx.Where("state_hash = ?", login.StateHash).Delete(&OIDCLogin{})
+		if rec.Error != nil {
+			return rec.Error
+		}
+		if rec.RowsAffected < 1 {
+			return ErrInvalidOIDCState
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return login, nil
+}
+
+// SignInWithIdentity finds the account identity signs in to. An identity seen
+// before keeps its account. Otherwise a verified email address links the
+// identity to the account with that address, or provisions a new one; created
+// reports the latter.
+func SignInWithIdentity(identity ExternalIdentity) (account *Account, created bool, err error) {
+	db := dao.GetDB()
+	now := time.Now()
+
+	linked := &AccountIdentity{}
+	err = db.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).Take(linked).Error
+	if err == nil {
+		db.Model(linked).Updates(map[string]interface{}{"email": identity.Email, "last_login_at": now})
+		if account = GetUser(linked.AccountID); account == nil {
+			return nil, false, ErrAccountNotFound
+		}
+		return account, false, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, false, err
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, false, ErrUnverifiedEmail
+	}
+
+	account = &Account{}
+	err = db.Table("accounts").Where("email = ?", identity.Email).First(account).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		// Provisioned accounts get a password nobody knows; a reset sets one
+		account = &Account{Email: identity.Email, Password: RandomToken(24)}
+		if resp := account.Create(); resp["status"] != true {
+			return nil, false, errIdentityNotCreated
+		}
+		created = true
+	case err != nil:
+		return nil, false, err
+	case !account.Verified():
+		// Whoever signed up with this address never proved they own it, so
+		// their password must not keep working now its owner has shown up
+		if err := ChangePassword(account.ID, RandomToken(24)); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := MarkVerified(account.ID, account.Email); err != nil {
+		return nil, false, err
+	}
+	if err := LinkIdentity(account.ID, identity); err != nil {
+		return nil, false, err
+	}
+	return GetUser(account.ID), created, nil
+}
+
+// LinkIdentity connects identity to the account so it can sign in with it
+func LinkIdentity(accountID uint, identity ExternalIdentity) error {
+	existing := &AccountIdentity{}
+	err := dao.GetDB().Where("provider = ? AND (subject = ? OR account_id = ?)", identity.Provider, identity.Subject, accountID).Take(existing).Error
+	if err == nil {
+		if existing.Subject != identity.Subject {
+			return ErrProviderLinked
+		}
+		if existing.AccountID != accountID {
+			return ErrIdentityTaken
+		}
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	now := time.Now()
+	return dao.GetDB().Create(&AccountIdentity{
+		AccountID:   accountID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}).Error
+}
+
+// UnlinkIdentity stops the account's identity at provider from signing in to it
+func UnlinkIdentity(accountID uint, provider string) error {
+	rec := dao.GetDB().Where("account_id = ? AND provider = ?", accountID, provider).Delete(&AccountIdentity{})
+	if rec.Error != nil {
+		return rec.Error
+	}
+	if rec.RowsAffected < 1 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Colour given to labels created without one
+const DefaultLabelColor = "#808080"
+
+// A label groups tasks of one workspace; names are unique per workspace
+type Label struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	AccountID   uint      `gorm:"index;not null" json:"account_id"` // Creator of the label
+	WorkspaceID uint      `gorm:"uniqueIndex:idx_labels_workspace_name;not null" json:"workspace_id"`
+	Name        string    `gorm:"uniqueIndex:idx_labels_workspace_name;not null" json:"name" binding:"required,min=1,max=50"`
+	Color       string    `gorm:"not null" json:"color" binding:"omitempty,hexcolor"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (label *Label) BeforeCreate(tx *gorm.DB) error {
+	if label.Color == "" {
+		label.Color = DefaultLabelColor
+	}
+	return nil
+}
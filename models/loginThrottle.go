@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Failed logins are counted per account and per client IP. After a few free
+// failures each further one doubles the wait before the next attempt, and at
+// MaxFailures the key is locked out outright.
+type ThrottlePolicy struct {
+	FreeFailures int           // Failures allowed before any backoff
+	MaxFailures  int           // Failures that lock the key out
+	Lockout      time.Duration // How long a lockout lasts
+}
+
+var (
+	AccountThrottle = ThrottlePolicy{FreeFailures: 2, MaxFailures: 5, Lockout: 15 * time.Minute}
+	// Addresses can be shared by many people, so they get more room
+	IPThrottle = ThrottlePolicy{FreeFailures: 10, MaxFailures: 50, Lockout: time.Hour}
+)
+
+const (
+	LoginBackoffBase = time.Second
+	LoginBackoffMax  = 5 * time.Minute
+	// Failures older than this are forgotten
+	LoginFailureWindow = 24 * time.Hour
+)
+
+// The failure count of one throttled key, "account:<id>" or "ip:<address>"
+type LoginThrottle struct {
+	Key           string     `gorm:"column:throttle_key;primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until"` // No attempts are checked before this
+	Locked        bool       `gorm:"not null;default:false" json:"locked"`
+}
+
+func AccountThrottleKey(accountID uint) string {
+	return fmt.Sprintf("account:%d", accountID)
+}
+
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delay is how long a key with failures waits before its next attempt, and
+// whether that is a lockout
+func (policy ThrottlePolicy) delay(failures int) (time.Duration, bool) {
+	if failures >= policy.MaxFailures {
+		return policy.Lockout, true
+	}
+	if failures <= policy.FreeFailures {
+		return 0, false
+	}
+	wait := LoginBackoffBase << (failures - policy.FreeFailures - 1)
+	if wait > LoginBackoffMax {
+		wait = LoginBackoffMax
+	}
+	return wait, false
+}
+
+// LoginBlocked reports how long the caller must wait before a login attempt
+// against any of keys is checked, and whether that is because one is locked out.
+func LoginBlocked(keys ...string) (time.Duration, bool) {
+	var throttles []LoginThrottle
+	dao.GetDB().Where("throttle_key IN ? AND blocked_until > ?", keys, time.Now()).Find(&throttles)
+
+	var wait time.Duration
+	locked := false
+	for _, throttle := range throttles {
+		if remaining := time.Until(*throttle.BlockedUntil); remaining > wait {
+			wait = remaining
+		}
+		locked = locked || throttle.Locked
+	}
+	return wait, locked
+}
+
+// RecordLoginFailure counts a failed attempt against key and blocks it for the
+// policy's backoff. It reports whether the failure locked the key out.
+func RecordLoginFailure(key string, policy ThrottlePolicy) (bool, error) {
+	locked := false
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Counted in one statement, so parallel failures all count; the row
+		// stays locked until the block below is written
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "throttle_key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", now.Add(-LoginFailureWindow)),
+				"last_failure_at": now,
+			}),
+		}).Create(&LoginThrottle{Key: key, Failures: 1, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+		var throttle LoginThrottle
+		if err := tx.Where("throttle_key = ?", key).Take(&throttle).Error; err != nil {
+			return err
+		}
+
+		wait, lockout := policy.delay(throttle.Failures)
+		var blockedUntil *time.Time
+		if wait > 0 {
+			until := now.Add(wait)
+			blockedUntil = &until
+		}
+		locked = lockout
+		return tx.Model(&LoginThrottle{}).Where("throttle_key = ?", key).
+			Updates(map[string]interface{}{"blocked_until": blockedUntil, "locked": lockout}).Error
+	})
+	return locked, err
+}
+
+// ClearLoginFailures forgets the failures of key, unlocking it
+func ClearLoginFailures(tx *gorm.DB, key string) error {
+	return tx.Where("throttle_key = ?", key).Delete(&LoginThrottle{}).Error
+}
+
+// AccountLocked reports whether the account is locked out after too many failures
+func AccountLocked(accountID uint) bool {
+	_, locked := LoginBlocked(AccountThrottleKey(accountID))
+	return locked
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How long a password reset link stays valid
+const PasswordResetLifetime = time.Hour
+
+// A single-use password reset token; only its hash is stored
+type PasswordReset struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	AccountID uint       `gorm:"index;not null" json:"account_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordReset stores a reset token for the account and returns its raw value
+func CreatePasswordReset(accountID uint) (string, error) {
+	return createPasswordReset(dao.GetDB(), accountID)
+}
+
+func createPasswordReset(tx *gorm.DB, accountID uint) (string, error) {
+	raw := RandomToken(32)
+	reset := &PasswordReset{
+		AccountID: accountID,
+		TokenHash: HashToken(raw),
+		ExpiresAt: time.Now().Add(PasswordResetLifetime),
+	}
+	if err := tx.Create(reset).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func changePassword(tx *gorm.DB, accountID uint, password string) error {
+	account := &Account{}
+	if err := account.SetPassword(password); err != nil {
+		return err
+	}
+	err := tx.Model(&Account{}).Where("id = ?", accountID).
+		Updates(map[string]interface{}{"password": account.Password, "password_changed_at": time.Now()}).Error
+	if err != nil {
+		return err
+	}
+	return revokeSessions(tx, "account_id = ?", accountID)
+}
+
+// ResetPassword uses up a reset token to set a new password for its account
+func ResetPassword(raw, password string) error {
+	reset := &PasswordReset{}
+	if err := dao.GetDB().Where("token_hash = ?", HashToken(raw)).First(reset).Error; err != nil {
+		return ErrInvalidResetToken
+	}
+
+	// Only one request can use the token, and only before it expires. It stays
+	// unused if the password cannot be changed.
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		rec := tx.Model(reset).Where("used_at IS NULL AND expires_at > ?", now).Update("used_at", now)
+		if rec.Error != nil {
+			return rec.Error
+		}
+		if rec.RowsAffected < 1 {
+			return ErrInvalidResetToken
+		}
+		return changePassword(tx, reset.AccountID, password)
+	})
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"task-management/dao"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxDisplayNameLength = 100
+	MaxAvatarURLLength   = 2048
+)
+
+// ProfileUpdate holds the profile fields a request changes; nil leaves a field as it is
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	Timezone    *string `json:"timezone"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+// ValidateProfile checks the fields account holders choose for themselves
+func (account *Account) ValidateProfile() error {
+	if utf8.RuneCountInString(account.DisplayName) > MaxDisplayNameLength {
+		return errors.New("display_name must be at most 100 characters")
+	}
+	if account.Timezone != "" {
+		if _, err := time.LoadLocation(account.Timezone); err != nil || account.Timezone == "Local" {
+			return errors.New("timezone must be an IANA time zone such as Europe/Paris")
+		}
+	}
+	if account.AvatarURL != "" {
+		parsed, err := url.Parse(account.AvatarURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" || len(account.AvatarURL) > MaxAvatarURLLength {
+			return errors.New("avatar_url must be an http(s) URL")
+		}
+	}
+	return nil
+}
+
+// UpdateProfile applies update to the account and saves the changed fields
+func UpdateProfile(account *Account, update ProfileUpdate) error {
+	changes := map[string]interface{}{}
+	if update.DisplayName != nil {
+		account.DisplayName = *update.DisplayName
+		changes["display_name"] = account.DisplayName
+	}
+	if update.Timezone != nil {
+		account.Timezone = *update.Timezone
+		changes["timezone"] = account.Timezone
+	}
+	if update.AvatarURL != nil {
+		account.AvatarURL = *update.AvatarURL
+		changes["avatar_url"] = account.AvatarURL
+	}
+	if err := account.ValidateProfile(); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return dao.GetDB().Model(&Account{}).Where("id = ?", account.ID).Updates(changes).Error
+}
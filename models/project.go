@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// A project groups tasks; task titles only need to be unique within one project
+type Project struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	AccountID     uint       `gorm:"index;not null" json:"account_id"` // Creator of the project
+	WorkspaceID   uint       `gorm:"uniqueIndex:idx_projects_workspace_name;not null" json:"workspace_id"`
+	Name          string     `gorm:"uniqueIndex:idx_projects_workspace_name;not null" json:"name" binding:"required,min=3,max=100"`
+	Description   string     `json:"description" binding:"max=500"`
+	DefaultStatus TaskStatus `gorm:"type:text;default:'pending'" json:"default_status"` // Status of new tasks that don't set one
+	ArchivedAt    *time.Time `json:"archived_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Validate checks the project fields that binding tags cannot
+func (project *Project) Validate() error {
+	if project.DefaultStatus != "" {
+		return project.DefaultStatus.IsValid()
+	}
+	return nil
+}
+
+func (project *Project) Archived() bool {
+	return project.ArchivedAt != nil
+}
+
+func (project *Project) BeforeCreate(tx *gorm.DB) error {
+	if project.DefaultStatus == "" {
+		project.DefaultStatus = StatusPending
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+	"task-management/dao"
+)
+
+// A member's role decides what they may do in a workspace
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+// A permission names an action routes can require, as resource:level
+type Permission string
+
+const (
+	PermTaskRead       Permission = "task:read"
+	PermTaskWrite      Permission = "task:write"
+	PermCommentWrite   Permission = "comment:write"
+	PermCommentAdmin   Permission = "comment:admin" // Delete anyone's comments
+	PermLabelRead      Permission = "label:read"
+	PermLabelWrite     Permission = "label:write"
+	PermProjectRead    Permission = "project:read"
+	PermProjectAdmin   Permission = "project:admin"
+	PermWorkspaceRead  Permission = "workspace:read"
+	PermWorkspaceAdmin Permission = "workspace:admin"
+)
+
+var viewerPermissions = []Permission{PermTaskRead, PermLabelRead, PermProjectRead, PermWorkspaceRead}
+var memberPermissions = append([]Permission{PermTaskWrite, PermCommentWrite, PermLabelWrite}, viewerPermissions...)
+var adminPermissions = append([]Permission{PermProjectAdmin, PermWorkspaceAdmin, PermCommentAdmin}, memberPermissions...)
+
+// Permissions granted to each role; owners differ from admins only in that they
+// cannot be removed or demoted.
+var rolePermissions = map[Role][]Permission{
+	RoleOwner:  adminPermissions,
+	RoleAdmin:  adminPermissions,
+	RoleMember: memberPermissions,
+	RoleViewer: viewerPermissions,
+}
+
+var ErrInvalidRole = errors.New("invalid role, must be one of: admin, member, viewer")
+
+// IsValid checks the role can be given to a member; there is only ever one owner
+func (role Role) IsValid() error {
+	switch role {
+	case RoleAdmin, RoleMember, RoleViewer:
+		return nil
+	}
+	return ErrInvalidRole
+}
+
+// Can reports whether the role grants permission
+func (role Role) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// MemberRole returns the role of an account in a workspace, or ErrNotMember
+func MemberRole(workspaceID, accountID uint) (Role, error) {
+	var member WorkspaceMember
+	err := dao.GetDB().Where("workspace_id = ? AND account_id = ?", workspaceID, accountID).Limit(1).Find(&member).Error
+	if err != nil {
+		return "", err
+	}
+	if member.AccountID == 0 {
+		return "", ErrNotMember
+	}
+	return member.Role, nil
+}
+
+// SetMemberRole changes the role of a member other than the owner
+func SetMemberRole(workspaceID, accountID uint, role Role) error {
+	if err := role.IsValid(); err != nil {
+		return err
+	}
+	rec := dao.GetDB().Model(&WorkspaceMember{}).
+		Where("workspace_id = ? AND account_id = ? AND role <> ?", workspaceID, accountID, RoleOwner).
+		Update("role", role)
+	if rec.Error != nil {
+		return rec.Error
+	}
+	if rec.RowsAffected < 1 {
+		return ErrNotMember
+	}
+	return nil
+}
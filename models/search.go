@@ -0,0 +1,142 @@
+package models
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"task-management/dao"
+	u "task-management/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Markers wrapped around matched terms in search snippets
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// Control characters the database marks matches with. Snippets are HTML escaped
+// with the markers in place, then the markers become highlightStart and
+// highlightEnd, so task text never reaches the snippet as markup.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+var markedSnippet = strings.NewReplacer(matchStart, highlightStart, matchEnd, highlightEnd)
+
+// Upper bound on the rows ranked in Go when the database has no full-text index
+const likeSearchCandidates = 500
+
+// A task matching a search, with its relevance and highlighted snippets. The
+// snippets are HTML: escaped task text with matches in <mark> elements.
+type TaskSearchResult struct {
+	Task
+	Rank               float64 `json:"rank"`
+	TitleSnippet       string  `json:"title_snippet"`
+	DescriptionSnippet string  `json:"description_snippet"`
+}
+
+// SearchTasks ranks the tasks selected by scope against the search text,
+// best match first. Terms must all appear in the title or description.
+func SearchTasks(scope func(*gorm.DB) *gorm.DB, text string, limit int) ([]TaskSearchResult, error) {
+	terms := strings.Fields(text)
+	results := []TaskSearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	var err error
+	switch dao.GetSearchBackend() {
+	case dao.SearchPostgres:
+		err = dao.GetDB().Table("tasks").
+			Select(`tasks.*, ts_rank(search_vector, query) AS rank,
+				ts_headline('english', title, query, ?) AS title_snippet,
+				ts_headline('english', description, query, ?) AS description_snippet`,
+				"StartSel="+matchStart+", StopSel="+matchEnd+", HighlightAll=true",
+				"StartSel="+matchStart+", StopSel="+matchEnd+", MaxFragments=2").
+			Joins("CROSS JOIN websearch_to_tsquery('english', ?) AS query", text).
+			Where("search_vector @@ query").
+			Scopes(scope).
+			Order("rank DESC").Limit(limit).
+			Scan(&results).Error
+	case dao.SearchFTS5:
+		// Quote every term so FTS5 query syntax in the input is matched literally
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		// bm25 is lower for better matches; flip it so rank reads the same on every backend
+		err = dao.GetDB().Table("tasks_fts").
+			Select(`tasks.*, -bm25(tasks_fts, 10.0, 1.0) AS rank,
+				highlight(tasks_fts, 0, ?, ?) AS title_snippet,
+				snippet(tasks_fts, 1, ?, ?, '…', 16) AS description_snippet`,
+				matchStart, matchEnd, matchStart, matchEnd).
+			Joins("JOIN tasks ON tasks.id = tasks_fts.rowid").
+			Where("tasks_fts MATCH ?", strings.Join(quoted, " ")).
+			Scopes(scope).
+			Order("rank DESC").Limit(limit).
+			Scan(&results).Error
+	default:
+		results, err = likeSearchTasks(scope, terms, limit)
+	}
+
+	// Scanned rows skip the AfterFind hook
+	now := time.Now()
+	for i := range results {
+		results[i].computeSchedule(now)
+		results[i].TitleSnippet = markedSnippet.Replace(html.EscapeString(results[i].TitleSnippet))
+		results[i].DescriptionSnippet = markedSnippet.Replace(html.EscapeString(results[i].DescriptionSnippet))
+	}
+	return results, err
+}
+
+// likeSearchTasks is the search used when the database has no full-text index.
+// Title hits weigh more than description hits, mirroring the indexed backends.
+func likeSearchTasks(scope func(*gorm.DB) *gorm.DB, terms []string, limit int) ([]TaskSearchResult, error) {
+	tx := dao.GetDB().Model(&Task{}).Scopes(scope)
+	for _, term := range terms {
+		pattern := "%" + u.EscapeLike(strings.ToLower(term)) + "%"
+		tx = tx.Where("(LOWER(title) LIKE ? ESCAPE '\\' OR LOWER(description) LIKE ? ESCAPE '\\')", pattern, pattern)
+	}
+	var tasks []Task
+	if err := tx.Limit(likeSearchCandidates).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	matcher := termMatcher(terms)
+	results := make([]TaskSearchResult, 0, len(tasks))
+	for _, task := range tasks {
+		titleHits := len(matcher.FindAllStringIndex(task.Title, -1))
+		descriptionHits := len(matcher.FindAllStringIndex(task.Description, -1))
+		results = append(results, TaskSearchResult{
+			Task:               task,
+			Rank:               float64(10*titleHits + descriptionHits),
+			TitleSnippet:       highlight(matcher, task.Title),
+			DescriptionSnippet: highlight(matcher, task.Description),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// termMatcher matches any of the terms, ignoring case
+func termMatcher(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+func highlight(matcher *regexp.Regexp, text string) string {
+	return matcher.ReplaceAllStringFunc(text, func(match string) string {
+		return matchStart + match + matchEnd
+	})
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AccessTokenLifetime  = 15 * time.Minute
+	RefreshTokenLifetime = 30 * 24 * time.Hour
+)
+
+// A refresh token can be exchanged once for a new access and refresh token. Every
+// token rotated out of one login shares its FamilyID, so the whole session can be
+// revoked at once. Only a hash of the token is stored.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	AccountID uint       `gorm:"index;not null" json:"account_id"`
+	FamilyID  string     `gorm:"index;not null" json:"family_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	AccessJTI string     `gorm:"not null" json:"-"` // The access token issued alongside this one
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"` // Set once the token has been rotated
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// A revoked access token, kept until the token would have expired anyway
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReuse   = errors.New("refresh token has already been used")
+)
+
+// RandomToken returns n random bytes encoded for use in URLs and headers
+func RandomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// HashToken is how tokens handed to clients are stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken stores a new refresh token in family and returns its raw value.
+// An empty family starts a new session.
+func IssueRefreshToken(accountID uint, family, accessJTI string) (string, *RefreshToken, error) {
+	if family == "" {
+		family = RandomToken(16)
+	}
+	raw := RandomToken(32)
+	token := &RefreshToken{
+		AccountID: accountID,
+		FamilyID:  family,
+		TokenHash: HashToken(raw),
+		AccessJTI: accessJTI,
+		ExpiresAt: time.Now().Add(RefreshTokenLifetime),
+	}
+	if err := dao.GetDB().Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+// RotateRefreshToken marks a refresh token used and returns it, so a replacement
+// can be issued in its family. Presenting a token that was already rotated means
+// it leaked: the whole family is revoked and ErrRefreshTokenReuse returned.
+func RotateRefreshToken(raw string) (*RefreshToken, error) {
+	token := &RefreshToken{}
+	if err := dao.GetDB().Where("token_hash = ?", HashToken(raw)).First(token).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Only one caller can win the rotation of a token
+	rec := dao.GetDB().Model(token).Where("used_at IS NULL").Update("used_at", time.Now())
+	if rec.Error != nil {
+		return nil, rec.Error
+	}
+	if rec.RowsAffected < 1 {
+		if err := RevokeSession(token.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReuse
+	}
+	return token, nil
+}
+
+// RevokeSession revokes every refresh token of a login and the access tokens issued with them
+func RevokeSession(family string) error {
+	return revokeSessions(dao.GetDB(), "family_id = ?", family)
+}
+
+// RevokeAccountSessions signs an account out everywhere
+func RevokeAccountSessions(accountID uint) error {
+	return revokeSessions(dao.GetDB(), "account_id = ?", accountID)
+}
+
+// revokeSessions revokes the refresh tokens matching the condition along with the
+// access tokens issued with them that have not expired yet.
+func revokeSessions(tx *gorm.DB, query string, arg interface{}) error {
+	var live []RefreshToken
+	err := tx.Where(query, arg).Where("created_at > ?", time.Now().Add(-AccessTokenLifetime)).Find(&live).Error
+	if err != nil {
+		return err
+	}
+	for _, token := range live {
+		if err := denyAccessToken(tx, token.AccessJTI, token.CreatedAt.Add(AccessTokenLifetime)); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&RefreshToken{}).Where(query, arg).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+}
+
+// DenyAccessToken puts an access token on the denylist until it expires
+func DenyAccessToken(jti string, expiresAt time.Time) error {
+	return denyAccessToken(dao.GetDB(), jti, expiresAt)
+}
+
+func denyAccessToken(tx *gorm.DB, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	// Expired entries can go, their tokens are rejected anyway
+	tx.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+	return tx.Where(RevokedToken{JTI: jti}).Attrs(RevokedToken{ExpiresAt: expiresAt}).FirstOrCreate(&RevokedToken{}).Error
+}
+
+// AccessTokenRevoked reports whether the access token with this jti was revoked
+func AccessTokenRevoked(jti string) bool {
+	var count int64
+	dao.GetDB().Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count)
+	return count > 0
+}
+
+// A signed in session, as listed to its account
+type Session struct {
+	ID           string    `json:"id"` // The family of the session's refresh tokens
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"` // When its tokens were last refreshed
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// ActiveSessions lists the sessions of an account that have not been revoked or
+// expired, most recently active first
+func ActiveSessions(accountID uint) ([]Session, error) {
+	var tokens []RefreshToken
+	err := dao.GetDB().Where("account_id = ? AND revoked_at IS NULL", accountID).Order("created_at, id").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byFamily := map[string]*Session{}
+	sessions := []Session{}
+	for _, token := range tokens {
+		session, ok := byFamily[token.FamilyID]
+		if !ok {
+			session = &Session{ID: token.FamilyID, CreatedAt: token.CreatedAt}
+			byFamily[token.FamilyID] = session
+		}
+		session.LastActiveAt, session.ExpiresAt = token.CreatedAt, token.ExpiresAt
+	}
+	now := time.Now()
+	for _, session := range byFamily {
+		if session.ExpiresAt.After(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastActiveAt.After(sessions[j].LastActiveAt) })
+	return sessions, nil
+}
+
+// SessionBelongsTo reports whether family is a session of the account
+func SessionBelongsTo(family string, accountID uint) bool {
+	var count int64
+	dao.GetDB().Model(&RefreshToken{}).Where("family_id = ? AND account_id = ?", family, accountID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"log"
+	"os"
+	"task-management/dao"
+	"task-management/jwtkeys"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How long a retired key keeps verifying; longer than any token it signed can
+// live (invitations, 7 days)
+const SigningKeyRetention = InvitationLifetime + 24*time.Hour
+
+// A key the app signs its JWTs with. The private key is stored as PKCS #8,
+// sealed with token_password when that is set.
+type SigningKey struct {
+	KID        string     `gorm:"column:kid;primaryKey"`
+	Algorithm  string     `gorm:"not null"`
+	PrivateKey []byte     `gorm:"not null"`
+	CreatedAt  time.Time  `gorm:"index"`
+	RetiredAt  *time.Time // No longer signs, still verifies
+	ExpiresAt  *time.Time `gorm:"index"` // No longer verifies
+}
+
+func signingKeySecret() string {
+	return os.Getenv("token_password")
+}
+
+// LoadSigningKeys returns the keys that have not expired. It is the source of the
+// app's jwtkeys.Keyring.
+func LoadSigningKeys() ([]*jwtkeys.Key, error) {
+	var stored []SigningKey
+	err := dao.GetDB().Where("expires_at IS NULL OR expires_at > ?", time.Now()).Order("created_at").Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*jwtkeys.Key, 0, len(stored))
+	for _, row := range stored {
+		der, err := jwtkeys.Open(row.PrivateKey, signingKeySecret())
+		if err != nil {
+			log.Printf("signing key %s cannot be opened, skipping it: %v", row.KID, err)
+			continue
+		}
+		key, err := jwtkeys.ParseKey(row.KID, row.Algorithm, der)
+		if err != nil {
+			log.Printf("signing key %s is invalid, skipping it: %v", row.KID, err)
+			continue
+		}
+		key.CreatedAt, key.RetiredAt, key.ExpiresAt = row.CreatedAt, row.RetiredAt, row.ExpiresAt
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// RotateSigningKey stores a new key for algorithm and retires the keys created
+// before it. Retired keys are deleted once they have expired.
+func RotateSigningKey(algorithm string) (*jwtkeys.Key, error) {
+	key, err := jwtkeys.Generate(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := key.MarshalPrivate()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := jwtkeys.Seal(der, signingKeySecret())
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored times keep microseconds, so keys are compared at that precision
+	key.CreatedAt = key.CreatedAt.Truncate(time.Microsecond)
+	now := time.Now()
+	err = dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&SigningKey{KID: key.ID, Algorithm: algorithm, PrivateKey: sealed, CreatedAt: key.CreatedAt}).Error
+		if err != nil {
+			return err
+		}
+		// Only keys older than this one: another instance rotating at the same
+		// time may have created a newer key, which must stay current
+		return tx.Model(&SigningKey{}).Where("retired_at IS NULL AND kid <> ? AND created_at < ?", key.ID, key.CreatedAt).
+			Updates(map[string]interface{}{"retired_at": now, "expires_at": now.Add(SigningKeyRetention)}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	dao.GetDB().Where("expires_at <= ?", now).Delete(&SigningKey{})
+	return key, nil
+}
+
+// RotateSigningKeyIfDue rotates when there is no current key, or the current one
+// is older than every. It reports whether it rotated.
+func RotateSigningKeyIfDue(algorithm string, every time.Duration) (bool, error) {
+	var current SigningKey
+	err := dao.GetDB().Where("retired_at IS NULL").Order("created_at DESC").Limit(1).Find(&current).Error
+	if err != nil {
+		return false, err
+	}
+	if current.KID != "" && current.Algorithm == algorithm && time.Since(current.CreatedAt) < every {
+		return false, nil
+	}
+	if _, err := RotateSigningKey(algorithm); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// InitSigningKeys makes sure a current key for algorithm exists and installs the
+// keyring the app signs and verifies tokens with
+func InitSigningKeys(algorithm string, rotateEvery time.Duration) error {
+	if _, err := RotateSigningKeyIfDue(algorithm, rotateEvery); err != nil {
+		return err
+	}
+	keyring := jwtkeys.NewKeyring(LoadSigningKeys)
+	if err := keyring.Refresh(); err != nil {
+		return err
+	}
+	jwtkeys.SetKeyring(keyring)
+	return nil
+}
@@ -2,9 +2,11 @@ package models
 
 import (
 	"errors"
+	"math"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
 )
 
 // Define Golang ENUM for Task Status
@@ -16,6 +18,9 @@ const (
 	StatusCompleted  TaskStatus = "completed"
 )
 
+// TaskStatuses lists every status a task can have
+var TaskStatuses = []TaskStatus{StatusPending, StatusInProgress, StatusCompleted}
+
 // Validate if TaskStatus is valid
 func (s TaskStatus) IsValid() error {
 	switch s {
@@ -25,13 +30,57 @@ func (s TaskStatus) IsValid() error {
 	return errors.New("invalid status")
 }
 
+// Define Golang ENUM for Task Priority, from least to most urgent
+type TaskPriority string
+
+const (
+	PriorityLow    TaskPriority = "low"
+	PriorityMedium TaskPriority = "medium"
+	PriorityHigh   TaskPriority = "high"
+	PriorityUrgent TaskPriority = "urgent"
+)
+
+// TaskPriorities lists the priorities in ascending order of urgency
+var TaskPriorities = []TaskPriority{PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// Validate if TaskPriority is valid
+func (p TaskPriority) IsValid() error {
+	if p.Rank() < 0 {
+		return errors.New("invalid priority")
+	}
+	return nil
+}
+
+// Rank orders priorities by urgency, low being 0. Invalid priorities rank -1.
+func (p TaskPriority) Rank() int {
+	for i, priority := range TaskPriorities {
+		if p == priority {
+			return i
+		}
+	}
+	return -1
+}
+
 type Task struct {
-	ID          uint       `gorm:"primaryKey" json:"id"`
-	Title       string     `gorm:"unique;not null" json:"title" binding:"required,min=3,max=255"`
-	Description string     `gorm:"not null" json:"description" binding:"required,min=5,max=500"`
-	Status      TaskStatus `gorm:"type:text;default:'pending'" json:"status"` // Use TEXT instead of ENUM
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID               uint         `gorm:"primaryKey" json:"id"`
+	Title            string       `gorm:"uniqueIndex:idx_tasks_project_title;not null" json:"title" binding:"required,min=3,max=255"`
+	Description      string       `gorm:"not null" json:"description" binding:"required,min=5,max=500"`
+	Status           TaskStatus   `gorm:"type:text;default:'pending'" json:"status"` // Use TEXT instead of ENUM
+	Priority         TaskPriority `gorm:"type:text;default:'medium';index" json:"priority"`
+	AccountID        uint         `gorm:"index" json:"account_id"`                               // Creator of the task
+	WorkspaceID      uint         `gorm:"index" json:"workspace_id"`                             // Workspace owning the task
+	ParentID         *uint        `gorm:"index" json:"parent_id"`                                // Task this one is a step of
+	ProjectID        *uint        `gorm:"uniqueIndex:idx_tasks_project_title" json:"project_id"` // Titles are unique within a project
+	RollupCompletion bool         `gorm:"not null;default:false" json:"rollup_completion"`       // Auto-complete when all subtasks are done
+	StartAt          *time.Time   `json:"start_at"`
+	DueAt            *time.Time   `gorm:"index" json:"due_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	Labels           []Label      `gorm:"many2many:task_labels" json:"labels,omitempty"`
+
+	// Derived from DueAt whenever the task is loaded or saved, never stored
+	IsOverdue     bool `gorm:"-" json:"is_overdue"`
+	DaysRemaining *int `gorm:"-" json:"days_remaining"`
 }
 
 // This method can be used to perform custom validations on the task model before saving it to the database.
@@ -43,5 +92,108 @@ func (task *Task) Validate() error {
 		return err
 	}
 
+	if task.Priority != "" {
+		if err := task.Priority.IsValid(); err != nil {
+			return err
+		}
+	}
+
+	if task.StartAt != nil && task.DueAt != nil && !task.StartAt.Before(*task.DueAt) {
+		return errors.New("start_at must be before due_at")
+	}
+
+	return nil
+}
+
+// Overdue reports whether the task is past its due date without being completed
+func (task *Task) Overdue(now time.Time) bool {
+	return task.DueAt != nil && task.DueAt.Before(now) && task.Status != StatusCompleted
+}
+
+// computeSchedule fills in the derived deadline fields
+func (task *Task) computeSchedule(now time.Time) {
+	task.IsOverdue = task.Overdue(now)
+	task.DaysRemaining = nil
+	if task.DueAt != nil {
+		days := int(math.Floor(task.DueAt.Sub(now).Hours() / 24))
+		task.DaysRemaining = &days
+	}
+}
+
+func (task *Task) BeforeCreate(tx *gorm.DB) error {
+	if task.Priority == "" {
+		task.Priority = PriorityMedium
+	}
+	return nil
+}
+
+// BeforeSave stores deadlines in UTC so they compare correctly as SQLite text
+func (task *Task) BeforeSave(tx *gorm.DB) error {
+	for _, t := range []**time.Time{&task.StartAt, &task.DueAt} {
+		if *t != nil {
+			utc := (*t).UTC()
+			*t = &utc
+		}
+	}
+	return nil
+}
+
+// reparentSubtasks moves the subtasks of task up to its parent
+func reparentSubtasks(tx *gorm.DB, actor *Account, task *Task) error {
+	var subtasks []Task
+	if err := tx.Where("parent_id = ?", task.ID).Find(&subtasks).Error; err != nil {
+		return err
+	}
+	if len(subtasks) == 0 {
+		return nil
+	}
+	if err := tx.Model(&Task{}).Where("parent_id = ?", task.ID).Update("parent_id", task.ParentID).Error; err != nil {
+		return err
+	}
+	for i := range subtasks {
+		before, err := SnapshotTask(&subtasks[i])
+		if err != nil {
+			return err
+		}
+		subtasks[i].ParentID = task.ParentID
+		if err := RecordTaskActivity(tx, actor, ActivityUpdated, &subtasks[i], before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (task *Task) AfterFind(tx *gorm.DB) error {
+	task.computeSchedule(time.Now())
 	return nil
 }
+
+func (task *Task) AfterSave(tx *gorm.DB) error {
+	task.computeSchedule(time.Now())
+	return nil
+}
+
+// DeleteTask deletes a task within tx. Its subtasks move up to its parent, as
+// recorded in their activity as actor's, and dependencies on it, its comments
+// and its attachments go away. The blobs of the attachments are left for SweepBlobs.
+// The parent then rolls up as it would when the task moved out of it.
+func DeleteTask(tx *gorm.DB, actor *Account, task *Task) error {
+	err := reparentSubtasks(tx, actor, task)
+	if err == nil {
+		err = tx.Where("task_id = ? OR blocker_id = ?", task.ID, task.ID).Delete(&TaskDependency{}).Error
+	}
+	if err == nil {
+		err = deleteTaskComments(tx, []uint{task.ID})
+	}
+	if err == nil {
+		err = deleteTaskAttachments(tx, []uint{task.ID})
+	}
+	// Selecting the labels association also removes the task's label links
+	if err == nil {
+		err = tx.Select("Labels").Delete(task).Error
+	}
+	if err == nil {
+		err = RollupCompletion(tx, actor, task.ParentID)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"task-management/dao"
+
+	"gorm.io/gorm"
+)
+
+// Deepest nesting followed when walking a task tree
+const maxTaskDepth = 100
+
+var ErrTaskCycle = errors.New("a task cannot be moved under itself or one of its subtasks")
+
+// A task together with its subtasks
+type TaskNode struct {
+	Task
+	Children []*TaskNode `json:"children"`
+}
+
+// LoadSubtree returns root with all of its descendants visible through scope
+func LoadSubtree(scope func(*gorm.DB) *gorm.DB, root *Task) (*TaskNode, error) {
+	tree := &TaskNode{Task: *root, Children: []*TaskNode{}}
+	frontier := map[uint]*TaskNode{root.ID: tree}
+	for depth := 0; len(frontier) > 0 && depth < maxTaskDepth; depth++ {
+		parentIDs := make([]uint, 0, len(frontier))
+		for id := range frontier {
+			parentIDs = append(parentIDs, id)
+		}
+		var children []Task
+		if err := dao.GetDB().Scopes(scope).Where("parent_id IN ?", parentIDs).Order("id").Find(&children).Error; err != nil {
+			return nil, err
+		}
+		next := map[uint]*TaskNode{}
+		for _, child := range children {
+			node := &TaskNode{Task: child, Children: []*TaskNode{}}
+			parent := frontier[*child.ParentID]
+			parent.Children = append(parent.Children, node)
+			next[child.ID] = node
+		}
+		frontier = next
+	}
+	return tree, nil
+}
+
+// CheckMove returns ErrTaskCycle if placing task under newParentID would make
+// the task its own ancestor. A nil parent makes the task top-level.
+func CheckMove(task *Task, newParentID *uint) error {
+	for id, depth := newParentID, 0; id != nil && depth < maxTaskDepth; depth++ {
+		if *id == task.ID {
+			return ErrTaskCycle
+		}
+		var ancestor Task
+		if err := dao.GetDB().Select("id", "parent_id").First(&ancestor, *id).Error; err != nil {
+			return err
+		}
+		id = ancestor.ParentID
+	}
+	return nil
+}
+
+// RollupCompletion completes, within tx, the task parentID, and in turn its
+// ancestors, when it has opted into roll-up, every one of its subtasks is
+// completed and nothing blocks it. Each completion is recorded in the task's
+// activity as actor's, whose change set it off.
+func RollupCompletion(tx *gorm.DB, actor *Account, parentID *uint) error {
+	for depth := 0; parentID != nil && depth < maxTaskDepth; depth++ {
+		var parent Task
+		if err := tx.First(&parent, *parentID).Error; err != nil {
+			return err
+		}
+		if !parent.RollupCompletion || parent.Status == StatusCompleted {
+			return nil
+		}
+		var children, open, blocked int64
+		tx.Model(&Task{}).Where("parent_id = ?", parent.ID).Count(&children)
+		tx.Model(&Task{}).Where("parent_id = ? AND status <> ?", parent.ID, StatusCompleted).Count(&open)
+		if children == 0 || open > 0 {
+			return nil
+		}
+		blockers := tx.Model(&TaskDependency{}).Select("blocker_id").Where("task_id = ?", parent.ID)
+		if err := tx.Model(&Task{}).Where("id IN (?) AND status <> ?", blockers, StatusCompleted).Count(&blocked).Error; err != nil || blocked > 0 {
+			return err
+		}
+
+		before, err := SnapshotTask(&parent)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&parent).Update("status", StatusCompleted).Error; err != nil {
+			return err
+		}
+		parent.Status = StatusCompleted
+		if err := RecordTaskActivity(tx, actor, ActivityUpdated, &parent, before); err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
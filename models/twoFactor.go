@@ -0,0 +1,150 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"task-management/dao"
+	"task-management/totp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Number of recovery codes handed out when two-factor login is enabled
+const RecoveryCodeCount = 10
+
+// A one-time code that stands in for a TOTP code when the authenticator is lost.
+// Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	AccountID uint       `gorm:"index;not null" json:"account_id"`
+	CodeHash  string     `gorm:"index;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor login is already enabled")
+	ErrTwoFactorNotStarted = errors.New("two-factor enrolment has not been started")
+	ErrInvalidTwoFactor    = errors.New("invalid two-factor code")
+)
+
+func (account *Account) TwoFactorEnabled() bool {
+	return account.TOTPEnabledAt != nil
+}
+
+// BeginTOTPEnrollment gives the account a new secret to load into an
+// authenticator app. Two-factor login is not enforced until ConfirmTOTP.
+func BeginTOTPEnrollment(account *Account) (string, error) {
+	if account.TwoFactorEnabled() {
+		return "", ErrTwoFactorEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	err = dao.GetDB().Model(&Account{}).Where("id = ?", account.ID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error
+	if err != nil {
+		return "", err
+	}
+	account.TOTPSecret = secret
+	return secret, nil
+}
+
+// ConfirmTOTP enables two-factor login once the account proves its authenticator
+// produces valid codes. It returns the recovery codes, which are never shown again.
+func ConfirmTOTP(account *Account, code string) ([]string, error) {
+	if account.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	if account.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotStarted
+	}
+	step, ok := totp.Validate(account.TOTPSecret, code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidTwoFactor
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	err := dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Account{}).Where("id = ?", account.ID).
+			Updates(map[string]interface{}{"totp_enabled_at": time.Now(), "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("account_id = ?", account.ID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := range codes {
+			codes[i] = newRecoveryCode()
+			if err := tx.Create(&RecoveryCode{AccountID: account.ID, CodeHash: HashToken(codes[i])}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns a code like 3f7q-k2xm-9vwd, easy to type from paper
+func newRecoveryCode() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:12]
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12]
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code for
+// the account. Each code only works once.
+func VerifySecondFactor(account *Account, code string) error {
+	code = strings.TrimSpace(code)
+	if step, ok := totp.Validate(account.TOTPSecret, code, time.Now(), 1); ok {
+		// A code seen before (or an older one) could have been observed in transit
+		rec := dao.GetDB().Model(&Account{}).Where("id = ? AND totp_last_step < ?", account.ID, step).Update("totp_last_step", step)
+		if rec.Error != nil {
+			return rec.Error
+		}
+		if rec.RowsAffected < 1 {
+			return ErrInvalidTwoFactor
+		}
+		return nil
+	}
+
+	rec := dao.GetDB().Model(&RecoveryCode{}).
+		Where("account_id = ? AND code_hash = ? AND used_at IS NULL", account.ID, HashToken(strings.ToLower(code))).
+		Update("used_at", time.Now())
+	if rec.Error != nil {
+		return rec.Error
+	}
+	if rec.RowsAffected < 1 {
+		return ErrInvalidTwoFactor
+	}
+	return nil
+}
+
+// DisableTOTP turns two-factor login off and drops the recovery codes
+func DisableTOTP(accountID uint) error {
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Account{}).Where("id = ?", accountID).
+			Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("account_id = ?", accountID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// RemainingRecoveryCodes counts the recovery codes the account has not used
+func RemainingRecoveryCodes(accountID uint) int64 {
+	var count int64
+	dao.GetDB().Model(&RecoveryCode{}).Where("account_id = ? AND used_at IS NULL", accountID).Count(&count)
+	return count
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"task-management/dao"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How long an invitation can be accepted for
+const InvitationLifetime = 7 * 24 * time.Hour
+
+// A workspace is the tenant that owns projects, tasks and labels. Every account
+// has a personal workspace and may be a member of any number of others.
+type Workspace struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name" binding:"required,min=3,max=100"`
+	OwnerID   uint      `gorm:"index;not null" json:"owner_id"`
+	Personal  bool      `gorm:"not null;default:false" json:"personal"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uint      `gorm:"primaryKey" json:"workspace_id"`
+	AccountID   uint      `gorm:"primaryKey;index" json:"account_id"`
+	Role        Role      `gorm:"type:text;default:'member'" json:"role"`
+	CreatedAt   time.Time `json:"joined_at"`
+}
+
+// A member as listed to other members of the workspace
+type MemberView struct {
+	AccountID uint      `json:"account_id"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+)
+
+// An invitation for the holder of Email to join a workspace
+type Invitation struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint             `gorm:"index;not null" json:"workspace_id"`
+	Email       string           `gorm:"index;not null" json:"email"`
+	InvitedByID uint             `gorm:"not null" json:"invited_by_id"`
+	Role        Role             `gorm:"type:text;default:'member'" json:"role"` // Role given on acceptance
+	Status      InvitationStatus `gorm:"type:text;default:'pending'" json:"status"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+	RespondedAt *time.Time       `json:"responded_at"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+var ErrNotMember = errors.New("not a member of this workspace")
+
+// CreateWorkspace stores workspace with owner as its first member
+func CreateWorkspace(workspace *Workspace, owner *Account) error {
+	workspace.OwnerID = owner.ID
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workspace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&WorkspaceMember{WorkspaceID: workspace.ID, AccountID: owner.ID, Role: RoleOwner}).Error
+	})
+}
+
+// EnsureActiveWorkspace makes sure the account's active workspace is one it
+// belongs to, falling back to its personal workspace. The personal workspace is
+// created on first use and adopts whatever the account owned before workspaces existed.
+func EnsureActiveWorkspace(account *Account) error {
+	if account.ActiveWorkspaceID != nil && IsMember(*account.ActiveWorkspaceID, account.ID) {
+		return nil
+	}
+
+	personal := &Workspace{}
+	err := dao.GetDB().Where("owner_id = ? AND personal = ?", account.ID, true).First(personal).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		personal = &Workspace{Name: account.Email, Personal: true}
+		if err = CreateWorkspace(personal, account); err == nil {
+			err = adoptUnscoped(account.ID, personal.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	account.ActiveWorkspaceID = &personal.ID
+	return dao.GetDB().Model(&Account{}).Where("id = ?", account.ID).Update("active_workspace_id", personal.ID).Error
+}
+
+// adoptUnscoped moves rows an account created before workspaces existed into workspaceID
+func adoptUnscoped(accountID, workspaceID uint) error {
+	for _, model := range []interface{}{&Task{}, &Label{}, &Project{}} {
+		err := dao.GetDB().Model(model).
+			Where("account_id = ? AND (workspace_id IS NULL OR workspace_id = 0)", accountID).
+			Update("workspace_id", workspaceID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func IsMember(workspaceID, accountID uint) bool {
+	var count int64
+	dao.GetDB().Model(&WorkspaceMember{}).Where("workspace_id = ? AND account_id = ?", workspaceID, accountID).Count(&count)
+	return count > 0
+}
+
+// Members lists the members of a workspace in the order they joined
+func Members(workspaceID uint) ([]MemberView, error) {
+	members := []MemberView{}
+	err := dao.GetDB().Table("workspace_members").
+		Select("workspace_members.account_id, accounts.email, workspace_members.role, workspace_members.created_at AS joined_at").
+		Joins("JOIN accounts ON accounts.id = workspace_members.account_id").
+		Where("workspace_members.workspace_id = ?", workspaceID).
+		Order("workspace_members.created_at, workspace_members.account_id").
+		Scan(&members).Error
+	return members, err
+}
+
+// AcceptInvitation adds the invited account to the workspace with the invited role
+func AcceptInvitation(invitation *Invitation, account *Account) error {
+	now := time.Now()
+	return dao.GetDB().Transaction(func(tx *gorm.DB) error {
+		member := WorkspaceMember{WorkspaceID: invitation.WorkspaceID, AccountID: account.ID}
+		if err := tx.Attrs(WorkspaceMember{Role: invitation.Role}).FirstOrCreate(&member, member).Error; err != nil {
+			return err
+		}
+		invitation.Status, invitation.RespondedAt = InvitationAccepted, &now
+		return tx.Save(invitation).Error
+	})
+}
+
+// RemoveMember takes an account out of a workspace; accounts that had it active
+// fall back to their personal workspace on their next request.
+func RemoveMember(workspaceID, accountID uint) error {
+	rec := dao.GetDB().Where("workspace_id = ? AND account_id = ?", workspaceID, accountID).Delete(&WorkspaceMember{})
+	if rec.Error != nil {
+		return rec.Error
+	}
+	if rec.RowsAffected < 1 {
+		return ErrNotMember
+	}
+	return dao.GetDB().Model(&Account{}).
+		Where("id = ? AND active_workspace_id = ?", accountID, workspaceID).
+		Update("active_workspace_id", nil).Error
+}
+
+// InvitationFor reports whether an invitation addresses the given email
+func (invitation *Invitation) InvitationFor(email string) bool {
+	return strings.EqualFold(invitation.Email, email)
+}
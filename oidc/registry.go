@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	u "task-management/utils"
+)
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]*Provider{}
+)
+
+// Register makes p available for sign in, replacing any provider of the same name
+func Register(p *Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name] = p
+}
+
+func Lookup(name string) (*Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Names lists the registered providers in order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FromEnv reads the providers listed in oidc_providers, a comma separated list of
+// names. Each name is configured by oidc_<name>_issuer, oidc_<name>_client_id,
+// oidc_<name>_client_secret and optionally oidc_<name>_scopes and
+// oidc_<name>_redirect_url; the redirect defaults to the app's callback route.
+func FromEnv() []*Provider {
+	var result []*Provider
+	for _, name := range strings.Split(os.Getenv("oidc_providers"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		env := func(key string) string { return os.Getenv("oidc_" + name + "_" + key) }
+		p := &Provider{
+			Name:         name,
+			Issuer:       env("issuer"),
+			ClientID:     env("client_id"),
+			ClientSecret: env("client_secret"),
+			RedirectURL:  env("redirect_url"),
+			Scopes:       strings.Fields(env("scopes")),
+		}
+		if p.RedirectURL == "" {
+			p.RedirectURL = u.AppURL() + "/auth/oidc/" + name + "/callback"
+		}
+		result = append(result, p)
+	}
+	return result
+}
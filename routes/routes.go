@@ -2,26 +2,153 @@ package routes
 
 import (
 	"task-management/controllers"
+	"task-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SetupRoutes registers the API. Authenticated routes declare the permission
+// they need in the caller's workspace; see models.Role for who holds which.
+// Personal access tokens only get through routes whose permission maps to one
+// of their scopes, everything else is SessionOnly.
 func SetupRoutes(router *gin.Engine) {
 	router.POST("/login", controllers.Login)
+	router.POST("/login/2fa", controllers.LoginTwoFactor) // Second step when two-factor login is on
 	router.POST("/account", controllers.CreateAccount)
-	// Public routes (no authentication required)
-	public := router.Group("/public")
+	router.GET("/account/verify", controllers.VerifyEmail) // Link emailed on sign-up
+	router.POST("/account/verify/resend", controllers.ResendVerification)
+	router.POST("/token/refresh", controllers.RefreshToken)
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS)                                         // Public keys that verify our tokens
+	router.POST("/logout", controllers.Authenticate(), controllers.SessionOnly(), controllers.Logout) // ?all=true signs out every session
+	router.POST("/password/forgot", controllers.ForgotPassword)                                       // Email a reset token
+	router.POST("/password/reset", controllers.ResetPassword)
+	router.POST("/password/change", controllers.Authenticate(), controllers.SessionOnly(), controllers.ChangePassword)
+
+	sso := router.Group("/auth/oidc")
+	{
+		sso.GET("/providers", controllers.GetOIDCProviders)
+		sso.GET("/:provider/login", controllers.OIDCLogin) // Redirects to the provider
+		sso.GET("/:provider/callback", controllers.OIDCCallback)
+		sso.GET("/identities", controllers.Authenticate(), controllers.SessionOnly(), controllers.GetIdentities)
+		sso.POST("/:provider/link", controllers.Authenticate(), controllers.SessionOnly(), controllers.LinkOIDCProvider)
+		sso.DELETE("/:provider/link", controllers.Authenticate(), controllers.SessionOnly(), controllers.UnlinkOIDCProvider)
+	}
+
+	me := router.Group("/me")
+	me.Use(controllers.Authenticate(), controllers.SessionOnly())
 	{
-		public.GET("/tasks", controllers.GetAllTasks) // List all tasks
+		me.GET("", controllers.GetMe)
+		me.PATCH("", controllers.UpdateMe)  // Display name, timezone, avatar
+		me.DELETE("", controllers.DeleteMe) // Takes the password and a task_policy of delete or reassign
+		me.GET("/sessions", controllers.GetSessions)
+		me.DELETE("/sessions/:id", controllers.RevokeMySession)
 	}
 
+	twoFactor := router.Group("/2fa")
+	twoFactor.Use(controllers.Authenticate(), controllers.SessionOnly())
+	{
+		twoFactor.POST("/enroll", controllers.EnrollTOTP) // Secret and otpauth:// URI for the authenticator app
+		twoFactor.POST("/confirm", controllers.ConfirmTOTP)
+		twoFactor.POST("/disable", controllers.DisableTOTP)
+	}
 	// Protected routes (authentication required)
 	protected := router.Group("/tasks")
 	protected.Use(controllers.Authenticate()) // Apply authentication middleware
 	{
-		protected.GET("/:id", controllers.GetTaskByID)   // Get task by ID
-		protected.POST("/", controllers.CreateTask)      // Create new task
-		protected.PUT("/:id", controllers.UpdateTask)    // Update task
-		protected.DELETE("/:id", controllers.DeleteTask) // Delete task
+		protected.GET("/", controllers.Require(models.PermTaskRead), controllers.GetAllTasks)                         // List the caller's tasks
+		protected.GET("/search", controllers.Require(models.PermTaskRead), controllers.SearchTasks)                   // Full-text search
+		protected.GET("/dependencies", controllers.Require(models.PermTaskRead), controllers.GetDependencyGraph)      // Dependency graph of the caller's tasks
+		protected.GET("/dependencies/order", controllers.Require(models.PermTaskRead), controllers.GetExecutionOrder) // Blockers-first execution order
+		protected.GET("/:id", controllers.Require(models.PermTaskRead), controllers.GetTaskByID)                      // Get task by ID
+		protected.POST("/", controllers.Require(models.PermTaskWrite), controllers.CreateTask)                        // Create new task
+		protected.PUT("/:id", controllers.Require(models.PermTaskWrite), controllers.UpdateTask)                      // Update task
+		protected.PATCH("/priority", controllers.Require(models.PermTaskWrite), controllers.BulkUpdatePriority)       // Re-prioritise many tasks
+		protected.DELETE("/:id", controllers.Require(models.PermTaskWrite), controllers.DeleteTask)                   // Delete task
+		protected.GET("/:id/subtree", controllers.Require(models.PermTaskRead), controllers.GetSubtree)               // Task with all its subtasks
+		protected.PUT("/:id/move", controllers.Require(models.PermTaskWrite), controllers.MoveTask)                   // Move a subtree under a new parent
+		protected.GET("/:id/dependencies", controllers.Require(models.PermTaskRead), controllers.GetTaskDependencies)
+		protected.POST("/:id/blockers", controllers.Require(models.PermTaskWrite), controllers.AddBlocker)
+		protected.DELETE("/:id/blockers/:blockerId", controllers.Require(models.PermTaskWrite), controllers.RemoveBlocker)
+		protected.POST("/:id/labels/:labelId", controllers.Require(models.PermTaskWrite), controllers.AttachLabel)
+		protected.DELETE("/:id/labels/:labelId", controllers.Require(models.PermTaskWrite), controllers.DetachLabel)
+		protected.GET("/:id/comments", controllers.Require(models.PermTaskRead), controllers.GetComments)        // Threads oldest first, ?limit=&cursor=
+		protected.POST("/:id/comments", controllers.Require(models.PermCommentWrite), controllers.CreateComment) // parent_id replies to a top-level comment
+		protected.PATCH("/:id/comments/:commentId", controllers.Require(models.PermCommentWrite), controllers.UpdateComment)
+		protected.DELETE("/:id/comments/:commentId", controllers.Require(models.PermCommentWrite), controllers.DeleteComment)
+		protected.GET("/:id/comments/:commentId/revisions", controllers.Require(models.PermTaskRead), controllers.GetCommentRevisions) // Earlier bodies, newest first
+		protected.GET("/:id/attachments", controllers.Require(models.PermTaskRead), controllers.GetAttachments)
+		protected.POST("/:id/attachments", controllers.Require(models.PermTaskWrite), controllers.CreateAttachment) // Multipart, in the file field
+		protected.GET("/:id/attachments/:attachmentId", controllers.Require(models.PermTaskRead), controllers.DownloadAttachment)
+		protected.DELETE("/:id/attachments/:attachmentId", controllers.Require(models.PermTaskWrite), controllers.DeleteAttachment)
+		protected.GET("/:id/history", controllers.Require(models.PermTaskRead), controllers.GetTaskHistory) // Who changed what, newest first
+	}
+
+	// Changes to every task of the workspace, newest first
+	router.GET("/activity", controllers.Authenticate(), controllers.Require(models.PermTaskRead), controllers.GetActivity)
+
+	labels := router.Group("/labels")
+	labels.Use(controllers.Authenticate())
+	{
+		labels.GET("/", controllers.Require(models.PermLabelRead), controllers.GetLabels)
+		labels.POST("/", controllers.Require(models.PermLabelWrite), controllers.CreateLabel)
+		labels.PUT("/:id", controllers.Require(models.PermLabelWrite), controllers.UpdateLabel)
+		labels.DELETE("/:id", controllers.Require(models.PermLabelWrite), controllers.DeleteLabel)
+	}
+
+	projects := router.Group("/projects")
+	projects.Use(controllers.Authenticate())
+	{
+		projects.GET("/", controllers.Require(models.PermProjectRead), controllers.GetProjects) // ?archived=true includes archived projects
+		projects.GET("/:id", controllers.Require(models.PermProjectRead), controllers.GetProject)
+		projects.POST("/", controllers.Require(models.PermProjectAdmin), controllers.CreateProject)
+		projects.PUT("/:id", controllers.Require(models.PermProjectAdmin), controllers.UpdateProject)
+		projects.DELETE("/:id", controllers.Require(models.PermProjectAdmin), controllers.DeleteProject)
+		projects.POST("/:id/archive", controllers.Require(models.PermProjectAdmin), controllers.ArchiveProject)
+		projects.POST("/:id/unarchive", controllers.Require(models.PermProjectAdmin), controllers.UnarchiveProject)
+		projects.GET("/:id/tasks", controllers.Require(models.PermTaskRead), controllers.GetProjectTasks)
+		projects.POST("/:id/tasks", controllers.Require(models.PermTaskWrite), controllers.CreateProjectTask)
+	}
+
+	workspaces := router.Group("/workspaces")
+	workspaces.Use(controllers.Authenticate(), controllers.SessionOnly())
+	{
+		workspaces.GET("/", controllers.GetWorkspaces)
+		workspaces.POST("/", controllers.CreateWorkspace)
+		workspaces.GET("/:id", controllers.RequireOnWorkspace(models.PermWorkspaceRead), controllers.GetWorkspace)                // Workspace with its members
+		workspaces.POST("/:id/activate", controllers.RequireOnWorkspace(models.PermWorkspaceRead), controllers.ActivateWorkspace) // Act on this workspace from now on
+		workspaces.POST("/:id/invitations", controllers.RequireOnWorkspace(models.PermWorkspaceAdmin), controllers.InviteMember)  // Email an invitation
+		workspaces.PUT("/:id/members/:accountId", controllers.RequireOnWorkspace(models.PermWorkspaceAdmin), controllers.UpdateMemberRole)
+		workspaces.DELETE("/:id/members/:accountId", controllers.RequireOnWorkspace(models.PermWorkspaceRead), controllers.RemoveMember) // Remove a member, or leave (needs workspace:admin unless leaving)
+	}
+
+	invitations := router.Group("/invitations")
+	invitations.Use(controllers.Authenticate(), controllers.SessionOnly())
+	{
+		invitations.GET("/", controllers.GetInvitations) // Pending invitations for the caller
+		invitations.POST("/accept", controllers.AcceptInvitation)
+		invitations.POST("/decline", controllers.DeclineInvitation)
+	}
+
+	admin := router.Group("/admin")
+	admin.Use(controllers.Authenticate(), controllers.SessionOnly(), controllers.RequireAdmin())
+	{
+		admin.GET("/accounts", controllers.ListAccounts)   // ?q= searches email and display name
+		admin.GET("/accounts/:id", controllers.GetAccount) // With task counts
+		admin.POST("/accounts/:id/suspend", controllers.SuspendAccount)
+		admin.POST("/accounts/:id/reactivate", controllers.ReactivateAccount)
+		admin.POST("/accounts/:id/password-reset", controllers.ForcePasswordReset) // Invalidate the password and email a reset link
+		admin.DELETE("/accounts/:id", controllers.DeleteAccount)
+		admin.POST("/accounts/:id/unlock", controllers.UnlockAccount)    // Lift a login lockout
+		admin.POST("/accounts/:id/impersonate", controllers.Impersonate) // Short-lived token acting as the account
+		admin.GET("/auth-events", controllers.GetAuthEvents)             // Sign-in attempts and lockouts
+		admin.GET("/audit-log", controllers.GetAuditLog)                 // What admins have done
+	}
+
+	tokens := router.Group("/tokens")
+	tokens.Use(controllers.Authenticate(), controllers.SessionOnly())
+	{
+		tokens.GET("/", controllers.GetAccessTokens) // Personal access tokens of the caller
+		tokens.POST("/", controllers.CreateAccessToken)
+		tokens.DELETE("/:id", controllers.RevokeAccessToken)
 	}
 }
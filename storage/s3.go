@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store keeps blobs as objects in a bucket of an S3-compatible service,
+// such as AWS S3 or MinIO
+type S3Store struct {
+	Endpoint  string // Base URL of the service, e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool         // Address the bucket in the path rather than the host name, as MinIO expects
+	Client    *http.Client // http.DefaultClient when nil
+}
+
+// s3Error is the body S3 sends along with failed requests
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint: %v", err)
+	}
+	object := *endpoint
+	base := strings.TrimSuffix(endpoint.Path, "/")
+	if s.PathStyle {
+		object.Path = base + "/" + s.Bucket + "/" + key
+	} else {
+		object.Host = s.Bucket + "." + endpoint.Host
+		object.Path = base + "/" + key
+	}
+	return &object, nil
+}
+
+// do sends a signed request for the object under key
+func (s *S3Store) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	object, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, object.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	SignV4(req, s.AccessKey, s.SecretKey, s.Region, "s3", UnsignedPayload, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// failure describes an unexpected response, closing its body
+func failure(method, key string, resp *http.Response) error {
+	defer resp.Body.Close()
+	var body s3Error
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if body.Code == "" {
+		return fmt.Errorf("storage: s3 %s %s: %s", method, key, resp.Status)
+	}
+	return fmt.Errorf("storage: s3 %s %s: %s: %s %s", method, key, resp.Status, body.Code, body.Message)
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, content, size, contentType)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return failure(http.MethodPut, key, resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		return nil, failure(http.MethodGet, key, resp)
+	}
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		resp.Body.Close()
+		return nil
+	default:
+		return failure(http.MethodDelete, key, resp)
+	}
+}
@@ -0,0 +1,95 @@
+// Package storage keeps blobs, such as the contents of task attachments, in a
+// BlobStore: a directory on the local filesystem or an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: blob not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// A BlobStore keeps blobs under keys of slash separated segments, such as
+// "attachments/ab/ab12...". Putting a key that exists replaces its blob and
+// deleting one that does not is not an error.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound when there is no blob under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey accepts keys whose segments are made of letters, digits, '-', '_'
+// and '.', and are not "." or ".."
+func validKey(key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+		for _, r := range segment {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+			}
+		}
+	}
+	return nil
+}
+
+// FromEnv picks the store configured in the environment: an S3Store when
+// blob_store is s3, otherwise a FileStore under blob_dir (data/blobs by default).
+func FromEnv() (BlobStore, error) {
+	switch kind := os.Getenv("blob_store"); kind {
+	case "s3":
+		store := &S3Store{
+			Endpoint:  os.Getenv("s3_endpoint"),
+			Region:    os.Getenv("s3_region"),
+			Bucket:    os.Getenv("s3_bucket"),
+			AccessKey: os.Getenv("s3_access_key"),
+			SecretKey: os.Getenv("s3_secret_key"),
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		if raw := os.Getenv("s3_path_style"); raw != "" {
+			pathStyle, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, fmt.Errorf("storage: invalid s3_path_style: %v", err)
+			}
+			store.PathStyle = pathStyle
+		}
+		if store.Endpoint == "" || store.Bucket == "" {
+			return nil, errors.New("storage: s3_endpoint and s3_bucket are required")
+		}
+		return store, nil
+	case "", "fs":
+		root := os.Getenv("blob_dir")
+		if root == "" {
+			root = "data/blobs"
+		}
+		return &FileStore{Root: root}, nil
+	default:
+		return nil, fmt.Errorf("storage: unknown blob_store %q", kind)
+	}
+}
+
+var store BlobStore = &FileStore{Root: "data/blobs"}
+
+// GetStore returns the store the app keeps its blobs in
+func GetStore() BlobStore {
+	return store
+}
+
+func SetStore(s BlobStore) {
+	store = s
+}
@@ -0,0 +1,90 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to mint a personal access token through the API
+func createAccessToken(t *testing.T, token string, body map[string]interface{}) models.PersonalAccessToken {
+	resp := sendAs(token, "POST", "/tokens/", body)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var pat models.PersonalAccessToken
+	json.Unmarshal(resp.Body.Bytes(), &pat)
+	return pat
+}
+
+// Test a read-only token can list tasks but not change them
+func TestAccessToken_Scopes(t *testing.T) {
+	_, token := createListingAccount("pat@taskmgmt.com", "CI visible task")
+	pat := createAccessToken(t, token, map[string]interface{}{"name": "ci", "scopes": []string{"tasks:read"}})
+	assert.Contains(t, pat.Token, models.PersonalAccessTokenPrefix)
+
+	_, page := listTasks(t, pat.Token, nil)
+	assert.Equal(t, []string{"CI visible task"}, titles(page.Data))
+
+	resp := sendAs(pat.Token, "POST", "/tasks/", map[string]string{"title": "From CI", "description": "Needs tasks:write"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	var denial struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &denial)
+	assert.Equal(t, "insufficient_scope", denial.Code)
+
+	// Tokens cannot manage the account that owns them
+	resp = sendAs(pat.Token, "POST", "/tokens/", map[string]interface{}{"name": "escalate", "scopes": []string{"tasks:write"}})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	writer := createAccessToken(t, token, map[string]interface{}{"name": "deploy", "scopes": []string{"tasks:read", "tasks:write"}})
+	resp = sendAs(writer.Token, "POST", "/tasks/", map[string]string{"title": "From CI", "description": "Created by automation"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+}
+
+// Test listing shows when tokens were last used, but never the token
+func TestAccessToken_ListAndRevoke(t *testing.T) {
+	_, token := createListingAccount("pat-list@taskmgmt.com")
+	pat := createAccessToken(t, token, map[string]interface{}{"name": "nightly", "scopes": []string{"tasks:read"}})
+	sendAs(pat.Token, "GET", "/tasks/", nil)
+
+	var tokens []models.PersonalAccessToken
+	resp := sendAs(token, "GET", "/tokens/", nil)
+	json.Unmarshal(resp.Body.Bytes(), &tokens)
+	if assert.Len(t, tokens, 1) {
+		assert.Empty(t, tokens[0].Token)
+		assert.NotNil(t, tokens[0].LastUsedAt)
+		assert.Equal(t, pat.Token[len(pat.Token)-4:], tokens[0].Hint)
+	}
+
+	resp = sendAs(testToken, "DELETE", fmt.Sprintf("/tokens/%d", pat.ID), nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	resp = sendAs(token, "DELETE", fmt.Sprintf("/tokens/%d", pat.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(pat.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+// ❌ **Test: Invalid and Expired Tokens**
+func TestAccessToken_Invalid(t *testing.T) {
+	_, token := createListingAccount("pat-invalid@taskmgmt.com")
+	resp := sendAs(token, "POST", "/tokens/", map[string]interface{}{"name": "bad", "scopes": []string{"projects:admin"}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs(token, "POST", "/tokens/", map[string]interface{}{"name": "bad", "scopes": []string{"tasks:read"}, "expires_at": time.Now().Add(-time.Hour)})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	pat := createAccessToken(t, token, map[string]interface{}{"name": "short", "scopes": []string{"tasks:read"}, "expires_at": time.Now().Add(time.Hour)})
+	resp = sendAs(pat.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	dao.GetDB().Model(&models.PersonalAccessToken{}).Where("id = ?", pat.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	resp = sendAs(pat.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = sendAs(models.PersonalAccessTokenPrefix+"made-up", "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
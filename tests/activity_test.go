@@ -0,0 +1,170 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to fetch activity entries from path
+func getActivity(t *testing.T, token, path string) []models.Activity {
+	resp := sendAs(token, "GET", path, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var entries []models.Activity
+	json.Unmarshal(resp.Body.Bytes(), &entries)
+	return entries
+}
+
+// Helper function to create a task through the API
+func createTaskAs(t *testing.T, token, title string) models.Task {
+	resp := sendAs(token, "POST", "/tasks/", map[string]string{"title": title, "description": "Tracked task"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var task models.Task
+	json.Unmarshal(resp.Body.Bytes(), &task)
+	return task
+}
+
+// changeOf finds the change to field in an entry
+func changeOf(entry models.Activity, field string) *models.FieldChange {
+	for i := range entry.Changes {
+		if entry.Changes[i].Field == field {
+			return &entry.Changes[i]
+		}
+	}
+	return nil
+}
+
+// Test a task's history records who created, changed and deleted it, field by field
+func TestActivity_TaskHistory(t *testing.T) {
+	_, token := createListingAccount("historian@taskmgmt.com")
+	task := createTaskAs(t, token, "Task with a past")
+	update := map[string]string{"title": task.Title, "description": "What it says now", "status": "in-progress"}
+	path := fmt.Sprintf("/tasks/%d", task.ID)
+	assert.Equal(t, http.StatusOK, sendAs(token, "PUT", path, update).Code)
+	assert.Equal(t, http.StatusOK, sendAs(token, "PUT", path, update).Code, "unchanged, so not recorded")
+	assert.Equal(t, http.StatusOK, sendAs(token, "DELETE", path, nil).Code)
+
+	// Still readable once the task is gone
+	history := getActivity(t, token, path+"/history")
+	if !assert.Len(t, history, 3) {
+		return
+	}
+	deleted, updated, created := history[0], history[1], history[2]
+	assert.Equal(t, models.ActivityCreated, created.Action)
+	assert.Equal(t, "historian@taskmgmt.com", created.ActorEmail)
+	if title := changeOf(created, "title"); assert.NotNil(t, title) {
+		assert.JSONEq(t, `null`, string(title.Before))
+		assert.JSONEq(t, `"Task with a past"`, string(title.After))
+	}
+
+	assert.Equal(t, models.ActivityUpdated, updated.Action)
+	assert.Len(t, updated.Changes, 2)
+	if description := changeOf(updated, "description"); assert.NotNil(t, description) {
+		assert.JSONEq(t, `"Tracked task"`, string(description.Before))
+		assert.JSONEq(t, `"What it says now"`, string(description.After))
+	}
+	if status := changeOf(updated, "status"); assert.NotNil(t, status) {
+		assert.JSONEq(t, `"pending"`, string(status.Before))
+		assert.JSONEq(t, `"in-progress"`, string(status.After))
+	}
+
+	assert.Equal(t, models.ActivityDeleted, deleted.Action)
+	assert.Equal(t, "Task with a past", deleted.TaskTitle)
+	if status := changeOf(deleted, "status"); assert.NotNil(t, status) {
+		assert.JSONEq(t, `null`, string(status.After))
+	}
+
+	// The log cannot be rewritten, and other workspaces cannot read it
+	assert.ErrorIs(t, dao.GetDB().Model(&updated).Update("actor_email", "someone@else.com").Error, models.ErrActivityImmutable)
+	assert.ErrorIs(t, dao.GetDB().Delete(&updated).Error, models.ErrActivityImmutable)
+	_, outsider := createListingAccount("history-outsider@taskmgmt.com")
+	assert.Equal(t, http.StatusNotFound, sendAs(outsider, "GET", path+"/history", nil).Code)
+}
+
+// Test changes made on the caller's behalf, roll-ups and re-parenting, are recorded as theirs
+func TestActivity_KnockOnChanges(t *testing.T) {
+	account, token := createListingAccount("knock-on@taskmgmt.com")
+	resp := sendAs(token, "POST", "/tasks/", map[string]interface{}{"title": "Rolled up parent", "description": "Done with its steps", "rollup_completion": true})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var parent models.Task
+	json.Unmarshal(resp.Body.Bytes(), &parent)
+	resp = sendAs(token, "POST", "/tasks/", map[string]interface{}{"title": "Only step", "description": "The one step", "parent_id": parent.ID})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var step models.Task
+	json.Unmarshal(resp.Body.Bytes(), &step)
+
+	resp = sendAs(token, "PUT", fmt.Sprintf("/tasks/%d", step.ID), map[string]string{"title": step.Title, "description": step.Description, "status": "completed"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	history := getActivity(t, token, fmt.Sprintf("/tasks/%d/history", parent.ID))
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, account.ID, history[0].ActorID)
+		if status := changeOf(history[0], "status"); assert.NotNil(t, status) {
+			assert.JSONEq(t, `"completed"`, string(status.After))
+		}
+	}
+
+	assert.Equal(t, http.StatusOK, sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d", parent.ID), nil).Code)
+	history = getActivity(t, token, fmt.Sprintf("/tasks/%d/history", step.ID))
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, account.ID, history[0].ActorID)
+		if moved := changeOf(history[0], "parent_id"); assert.NotNil(t, moved) {
+			assert.JSONEq(t, fmt.Sprint(parent.ID), string(moved.Before))
+			assert.JSONEq(t, `null`, string(moved.After))
+		}
+	}
+}
+
+// Test the workspace feed filters by task, actor, action, field and time
+func TestActivity_Feed(t *testing.T) {
+	owner, ownerToken := createListingAccount("feed-owner@taskmgmt.com")
+	workspace := models.Workspace{ID: *owner.ActiveWorkspaceID}
+	member, memberToken := joinWorkspace(t, ownerToken, workspace, "feed-member@taskmgmt.com", models.RoleMember)
+	start := time.Now().Add(-time.Second)
+
+	first := createTaskAs(t, ownerToken, "First feed task")
+	second := createTaskAs(t, memberToken, "Second feed task")
+	resp := sendAs(memberToken, "PATCH", "/tasks/priority", controllers.BulkPriorityRequest{IDs: []uint{first.ID, second.ID}, Priority: models.PriorityUrgent})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(ownerToken, "PUT", fmt.Sprintf("/tasks/%d/move", second.ID), controllers.MoveTaskRequest{ParentID: &first.ID})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	assert.Len(t, getActivity(t, ownerToken, "/activity"), 5)
+	assert.Len(t, getActivity(t, ownerToken, fmt.Sprintf("/activity?task_id=%d", second.ID)), 3)
+	byMember := getActivity(t, ownerToken, fmt.Sprintf("/activity?actor_id=%d&action=updated", member.ID))
+	if assert.Len(t, byMember, 2) {
+		priority := changeOf(byMember[0], "priority")
+		if assert.NotNil(t, priority) {
+			assert.JSONEq(t, `"urgent"`, string(priority.After))
+		}
+	}
+	moves := getActivity(t, memberToken, "/activity?field=parent_id")
+	if assert.Len(t, moves, 1) {
+		assert.Equal(t, second.ID, moves[0].TaskID)
+		assert.Equal(t, owner.ID, moves[0].ActorID)
+	}
+
+	// Pages go back in time
+	page := getActivity(t, ownerToken, "/activity?limit=3")
+	if assert.Len(t, page, 3) {
+		rest := getActivity(t, ownerToken, fmt.Sprintf("/activity?limit=3&before=%d", page[2].ID))
+		assert.Len(t, rest, 2)
+	}
+	since := url.QueryEscape(start.Format(time.RFC3339))
+	assert.Len(t, getActivity(t, ownerToken, "/activity?since="+since), 5)
+	assert.Empty(t, getActivity(t, ownerToken, "/activity?until="+since))
+
+	assert.Equal(t, http.StatusBadRequest, sendAs(ownerToken, "GET", "/activity?field=account_id", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, sendAs(ownerToken, "GET", "/activity?action=renamed", nil).Code)
+
+	// Other workspaces see none of it
+	_, outsider := createListingAccount("feed-outsider@taskmgmt.com")
+	assert.Empty(t, getActivity(t, outsider, "/activity"))
+}
@@ -0,0 +1,201 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to log in a new site administrator
+func loginAdmin(t *testing.T, email string) sessionTokens {
+	session := login(t, email)
+	models.GrantAdmin([]string{email})
+	return session
+}
+
+// Helper function to read the audit trail entries about an account
+func auditEntries(t *testing.T, token string, targetID uint) []models.AuditLog {
+	resp := sendAs(token, "GET", fmt.Sprintf("/admin/audit-log?target_id=%d", targetID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var entries []models.AuditLog
+	json.Unmarshal(resp.Body.Bytes(), &entries)
+	return entries
+}
+
+// Test admins can search accounts and see how many tasks they created
+func TestAdmin_ListAccounts(t *testing.T) {
+	admin := loginAdmin(t, "console-admin@taskmgmt.com")
+	account, _ := createListingAccount("console-findme@taskmgmt.com", "First", "Second", "Third")
+	createListingAccount("console-other@taskmgmt.com")
+
+	outsider := login(t, "console-outsider@taskmgmt.com")
+	assert.Equal(t, http.StatusForbidden, sendAs(outsider.Token, "GET", "/admin/accounts", nil).Code)
+
+	resp := sendAs(admin.Token, "GET", "/admin/accounts?q=FINDME", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var accounts []models.AccountSummary
+	json.Unmarshal(resp.Body.Bytes(), &accounts)
+	if assert.Len(t, accounts, 1) {
+		assert.Equal(t, account.ID, accounts[0].ID)
+		assert.Equal(t, int64(3), accounts[0].TaskCount)
+	}
+	assert.NotContains(t, resp.Body.String(), "password")
+
+	resp = sendAs(admin.Token, "GET", fmt.Sprintf("/admin/accounts/%d", account.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var detail struct {
+		Tasks struct {
+			Total    int64                       `json:"total"`
+			ByStatus map[models.TaskStatus]int64 `json:"by_status"`
+		} `json:"tasks"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &detail)
+	assert.Equal(t, int64(3), detail.Tasks.Total)
+	assert.Equal(t, int64(2), detail.Tasks.ByStatus[models.StatusPending])
+	assert.Equal(t, int64(1), detail.Tasks.ByStatus[models.StatusCompleted])
+
+	assert.Equal(t, http.StatusBadRequest, sendAs(admin.Token, "GET", "/admin/accounts?status=banned", nil).Code)
+}
+
+// Test a suspended account is signed out and kept out until reactivated
+func TestAdmin_SuspendAndReactivate(t *testing.T) {
+	admin := loginAdmin(t, "suspender@taskmgmt.com")
+	session := login(t, "suspendee@taskmgmt.com")
+	target := getMe(t, session.Token)
+	pat := createAccessToken(t, session.Token, map[string]interface{}{"name": "ci", "scopes": []string{"tasks:read"}})
+
+	resp := sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/suspend", target.ID), controllers.SuspendAccountRequest{Reason: "spam"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusConflict, sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/suspend", target.ID), nil).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, sendAs(session.Token, "GET", "/me", nil).Code)
+	resp = sendAs(pat.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Contains(t, resp.Body.String(), "account_suspended")
+	code, _ := refresh(session.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	resp = sendAs("", "POST", "/login", controllers.LoginRequest{Email: target.Email, Password: "password123"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/reactivate", target.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	loginAs(t, target.Email, "password123")
+	assert.Equal(t, http.StatusOK, sendAs(pat.Token, "GET", "/tasks/", nil).Code)
+
+	// Admins cannot lock themselves out
+	me := getMe(t, admin.Token)
+	assert.Equal(t, http.StatusBadRequest, sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/suspend", me.ID), nil).Code)
+
+	entries := auditEntries(t, admin.Token, target.ID)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, models.AuditAccountReactivated, entries[0].Action)
+		assert.Equal(t, models.AuditAccountSuspended, entries[1].Action)
+		assert.Equal(t, "spam", entries[1].Detail)
+		assert.Equal(t, me.ID, entries[1].ActorID)
+		assert.Equal(t, target.Email, entries[1].TargetEmail)
+	}
+}
+
+// Test a forced reset invalidates the password and emails a working reset token
+func TestAdmin_ForcePasswordReset(t *testing.T) {
+	admin := loginAdmin(t, "resetter@taskmgmt.com")
+	session := login(t, "compromised@taskmgmt.com")
+	target := getMe(t, session.Token)
+
+	sent := captureMail(t, func() {
+		resp := sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/password-reset", target.ID), nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+	if !assert.Len(t, sent, 1) {
+		return
+	}
+	assert.Equal(t, target.Email, sent[0].To)
+	assert.Equal(t, http.StatusUnauthorized, sendAs(session.Token, "GET", "/me", nil).Code)
+	resp := sendAs("", "POST", "/login", controllers.LoginRequest{Email: target.Email, Password: "password123"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	token := resetTokenPattern.FindString(sent[0].Body)
+	resp = sendAs("", "POST", "/password/reset", controllers.ResetPasswordRequest{Token: token, Password: "brand-new-password"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	loginAs(t, target.Email, "brand-new-password")
+
+	entries := auditEntries(t, admin.Token, target.ID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.AuditPasswordResetForced, entries[0].Action)
+	}
+}
+
+// Test admins can delete accounts and the trail outlives them
+func TestAdmin_DeleteAccount(t *testing.T) {
+	admin := loginAdmin(t, "deleter@taskmgmt.com")
+	target, _ := createListingAccount("deletee@taskmgmt.com", "Doomed")
+	path := fmt.Sprintf("/admin/accounts/%d", target.ID)
+
+	assert.Equal(t, http.StatusBadRequest, sendAs(admin.Token, "DELETE", path, nil).Code)
+	resp := sendAs(admin.Token, "DELETE", path, controllers.AdminDeleteAccountRequest{TaskPolicy: models.TaskPolicyDelete})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(admin.Token, "GET", path, nil).Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(admin.Token, "DELETE", path, controllers.AdminDeleteAccountRequest{TaskPolicy: models.TaskPolicyDelete}).Code)
+
+	entries := auditEntries(t, admin.Token, target.ID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.AuditAccountDeleted, entries[0].Action)
+		assert.Equal(t, "deletee@taskmgmt.com", entries[0].TargetEmail)
+	}
+}
+
+// Test reading accounts, the auth event log and the audit trail is itself in the audit trail
+func TestAdmin_AuditsReads(t *testing.T) {
+	admin := loginAdmin(t, "reader-admin@taskmgmt.com")
+	me := getMe(t, admin.Token)
+	target, _ := createListingAccount("read-about@taskmgmt.com")
+
+	assert.Equal(t, http.StatusOK, sendAs(admin.Token, "GET", "/admin/accounts?q=read-about", nil).Code)
+	assert.Equal(t, http.StatusOK, sendAs(admin.Token, "GET", fmt.Sprintf("/admin/accounts/%d", target.ID), nil).Code)
+	assert.Equal(t, http.StatusOK, sendAs(admin.Token, "GET", "/admin/auth-events?email=read-about%40taskmgmt.com", nil).Code)
+
+	resp := sendAs(admin.Token, "GET", fmt.Sprintf("/admin/audit-log?actor_id=%d", me.ID), nil)
+	var entries []models.AuditLog
+	json.Unmarshal(resp.Body.Bytes(), &entries)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, models.AuditAuthEventsViewed, entries[0].Action)
+		assert.Equal(t, "email=read-about%40taskmgmt.com", entries[0].Detail)
+		assert.Equal(t, models.AuditAccountViewed, entries[1].Action)
+		assert.Equal(t, target.ID, entries[1].TargetID)
+		assert.Equal(t, models.AuditAccountsListed, entries[2].Action)
+		assert.Equal(t, "q=read-about", entries[2].Detail)
+	}
+
+	// Reading the trail is in it too
+	resp = sendAs(admin.Token, "GET", fmt.Sprintf("/admin/audit-log?actor_id=%d", me.ID), nil)
+	json.Unmarshal(resp.Body.Bytes(), &entries)
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, models.AuditAuditLogViewed, entries[0].Action)
+		assert.Equal(t, fmt.Sprintf("actor_id=%d", me.ID), entries[0].Detail)
+	}
+}
+
+// Test nothing is done or shown when the audit trail cannot record it
+func TestAdmin_AuditUnavailable(t *testing.T) {
+	admin := loginAdmin(t, "unaudited-admin@taskmgmt.com")
+	session := login(t, "unaudited@taskmgmt.com")
+	target := getMe(t, session.Token)
+
+	migrator := dao.GetDB().Migrator()
+	assert.NoError(t, migrator.RenameTable("audit_logs", "audit_logs_offline"))
+	suspend := sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/suspend", target.ID), nil)
+	view := sendAs(admin.Token, "GET", fmt.Sprintf("/admin/accounts/%d", target.ID), nil)
+	assert.NoError(t, migrator.RenameTable("audit_logs_offline", "audit_logs"))
+
+	assert.Equal(t, http.StatusInternalServerError, suspend.Code)
+	assert.Equal(t, http.StatusOK, sendAs(session.Token, "GET", "/me", nil).Code)
+	assert.Equal(t, http.StatusInternalServerError, view.Code)
+	assert.NotContains(t, view.Body.String(), target.Email)
+	assert.Empty(t, auditEntries(t, admin.Token, target.ID))
+}
@@ -0,0 +1,166 @@
+package tests_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"task-management/dao"
+	"task-management/models"
+	"task-management/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A one pixel PNG
+var pixelPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+// Helper function to upload a file to a task as the file field of a multipart form
+func upload(token string, taskID uint, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(content)
+	form.Close()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/tasks/%d/attachments", taskID), &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+	return resp
+}
+
+func uploadAttachment(t *testing.T, token string, taskID uint, filename string, content []byte) models.Attachment {
+	resp := upload(token, taskID, filename, content)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var attachment models.Attachment
+	json.Unmarshal(resp.Body.Bytes(), &attachment)
+	return attachment
+}
+
+// blobStored reports whether the blob store holds the blob with the given hash
+func blobStored(sum string) bool {
+	blob, err := storage.GetStore().Get(context.Background(), models.BlobKey(sum))
+	if err != nil {
+		return false
+	}
+	blob.Close()
+	return true
+}
+
+// Test attachments upload, list and download as they were uploaded
+func TestAttachments_UploadAndDownload(t *testing.T) {
+	account, token := createListingAccount("attacher@taskmgmt.com")
+	task := createCommentedTask(account, "Task with files")
+
+	image := uploadAttachment(t, token, task.ID, `C:\Users\me\pixel.png`, pixelPNG)
+	assert.Equal(t, "pixel.png", image.Filename)
+	assert.Equal(t, "image/png", image.ContentType)
+	assert.Equal(t, int64(len(pixelPNG)), image.Size)
+	notes := uploadAttachment(t, token, task.ID, "notes.txt", []byte("Remember the milk"))
+	assert.Equal(t, "text/plain; charset=utf-8", notes.ContentType)
+
+	resp := sendAs(token, "GET", fmt.Sprintf("/tasks/%d/attachments", task.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var attachments []models.Attachment
+	json.Unmarshal(resp.Body.Bytes(), &attachments)
+	if assert.Len(t, attachments, 2) {
+		assert.Equal(t, image.ID, attachments[0].ID)
+	}
+
+	resp = sendAs(token, "GET", fmt.Sprintf("/tasks/%d/attachments/%d", task.ID, image.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, pixelPNG, resp.Body.Bytes())
+	assert.Equal(t, "image/png", resp.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=pixel.png`, resp.Header().Get("Content-Disposition"))
+
+	// Attachments of tasks in other workspaces are out of reach
+	_, outsider := createListingAccount("attachment-outsider@taskmgmt.com")
+	resp = sendAs(outsider, "GET", fmt.Sprintf("/tasks/%d/attachments/%d", task.ID, image.ID), nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, http.StatusNotFound, upload(outsider, task.ID, "sneaky.txt", []byte("hi")).Code)
+}
+
+// Test uploads are refused past the size limit and outside the allowed types
+func TestAttachments_Limits(t *testing.T) {
+	account, token := createListingAccount("limited-attacher@taskmgmt.com")
+	task := createCommentedTask(account, "Task with limits")
+
+	// Types are detected from the contents, whatever the name says
+	resp := upload(token, task.ID, "page.txt", []byte("<html><body>Hello</body></html>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+	resp = upload(token, task.ID, "empty.txt", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	os.Setenv("attachment_max_size", "16")
+	defer os.Unsetenv("attachment_max_size")
+	resp = upload(token, task.ID, "long.txt", []byte("This note is longer than sixteen bytes"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	uploadAttachment(t, token, task.ID, "short.txt", []byte("Short note"))
+
+	os.Setenv("attachment_types", "image/png")
+	defer os.Unsetenv("attachment_types")
+	resp = upload(token, task.ID, "other.txt", []byte("Plain text"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+
+	// Viewers can download but not upload
+	workspace := models.Workspace{ID: *account.ActiveWorkspaceID}
+	_, viewerToken := joinWorkspace(t, token, workspace, "attachment-viewer@taskmgmt.com", models.RoleViewer)
+	assert.Equal(t, http.StatusForbidden, upload(viewerToken, task.ID, "pixel.png", pixelPNG).Code)
+}
+
+// Test identical contents share one blob, kept until the last attachment using it goes
+func TestAttachments_Deduplication(t *testing.T) {
+	account, token := createListingAccount("dedup-attacher@taskmgmt.com")
+	task := createCommentedTask(account, "First task with the report")
+	other := createCommentedTask(account, "Second task with the report")
+	content := []byte("Quarterly report, shared by two tasks")
+
+	first := uploadAttachment(t, token, task.ID, "report.txt", content)
+	second := uploadAttachment(t, token, other.ID, "copy.txt", content)
+	assert.Equal(t, first.SHA256, second.SHA256)
+	assert.True(t, blobStored(first.SHA256))
+
+	resp := sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d/attachments/%d", task.ID, first.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.True(t, blobStored(first.SHA256), "the other task still uses the blob")
+	resp = sendAs(token, "GET", fmt.Sprintf("/tasks/%d/attachments/%d", other.ID, second.ID), nil)
+	assert.Equal(t, content, resp.Body.Bytes())
+
+	// Deleting the task removes its attachments; the sweep then removes the blob
+	// nothing uses any more, once its grace period is over
+	resp = sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d", other.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var count int64
+	dao.GetDB().Model(&models.Attachment{}).Where("task_id = ?", other.ID).Count(&count)
+	assert.Zero(t, count)
+	assert.NoError(t, models.SweepBlobs(context.Background(), storage.GetStore(), models.BlobSweepGrace))
+	assert.True(t, blobStored(first.SHA256), "still within the grace period")
+	assert.NoError(t, models.SweepBlobs(context.Background(), storage.GetStore(), 0))
+	assert.False(t, blobStored(first.SHA256))
+	dao.GetDB().Model(&models.OrphanedBlob{}).Where("sha256 = ?", first.SHA256).Count(&count)
+	assert.Zero(t, count)
+}
+
+// Test uploading contents again after their last attachment went keeps the blob from the sweep
+func TestAttachments_ReuploadBeforeSweep(t *testing.T) {
+	account, token := createListingAccount("reuploader@taskmgmt.com")
+	task := createCommentedTask(account, "Task with a returning file")
+	content := []byte("Contents deleted and uploaded again")
+
+	gone := uploadAttachment(t, token, task.ID, "draft.txt", content)
+	resp := sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d/attachments/%d", task.ID, gone.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	back := uploadAttachment(t, token, task.ID, "final.txt", content)
+
+	assert.NoError(t, models.SweepBlobs(context.Background(), storage.GetStore(), 0))
+	resp = sendAs(token, "GET", fmt.Sprintf("/tasks/%d/attachments/%d", task.ID, back.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, content, resp.Body.Bytes())
+}
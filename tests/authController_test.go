@@ -16,22 +16,29 @@ import (
 	"gorm.io/gorm"
 )
 
-func setupAuthRouter() *gin.Engine {
+func setupAuthRouter(t *testing.T) *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	// Restore the shared database once the test is done so other tests keep their data
+	prev := dao.GetDB()
+	t.Cleanup(func() { dao.SetDb(prev) })
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	dao.SetDb(db)
 	dao.GetDB().AutoMigrate(&models.Account{})
+	dao.GetDB().AutoMigrate(&models.RefreshToken{})
+	dao.GetDB().AutoMigrate(&models.LoginThrottle{})
+	dao.GetDB().AutoMigrate(&models.AuthEvent{})
 	router := gin.Default()
 	router.POST("/login", controllers.Login)
 	return router
 }
 
 func TestLoginSuccess(t *testing.T) {
-	router := setupAuthRouter()
+	router := setupAuthRouter(t)
 
-	// Create an account
+	// Create an account; Create stores the password hashed
 	acct := models.Account{Email: "admin@taskmgmt.com", Password: "login test"}
-	dao.GetDB().Create(&acct)
+	acct.Create()
+	models.MarkVerified(acct.ID, acct.Email)
 
 	loginData := controllers.LoginRequest{
 		Email:    "admin@taskmgmt.com",
@@ -55,7 +62,7 @@ func TestLoginSuccess(t *testing.T) {
 }
 
 func TestLoginFailure(t *testing.T) {
-	router := setupAuthRouter()
+	router := setupAuthRouter(t)
 
 	loginData := controllers.LoginRequest{
 		Email:    "wronguser@gmail.com",
@@ -0,0 +1,79 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to add a new account to a workspace with role, returning its
+// token with the workspace already active
+func joinWorkspace(t *testing.T, ownerToken string, workspace models.Workspace, email string, role models.Role) (models.Account, string) {
+	account, token := createListingAccount(email)
+	invitation := invite(t, ownerToken, workspace, email, role)
+	resp := sendAs(token, "POST", "/invitations/accept", map[string]string{"token": invitation})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(token, "POST", fmt.Sprintf("/workspaces/%d/activate", workspace.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	return account, token
+}
+
+// Test viewers can read the workspace but get a structured 403 when writing
+func TestRoles_Viewer(t *testing.T) {
+	_, ownerToken := createListingAccount("rbac-owner@taskmgmt.com")
+	workspace := createWorkspace(t, ownerToken, "Stakeholders")
+	sendAs(ownerToken, "POST", fmt.Sprintf("/workspaces/%d/activate", workspace.ID), nil)
+	sendAs(ownerToken, "POST", "/tasks/", map[string]string{"title": "Quarterly plan", "description": "For stakeholders"})
+	_, viewerToken := joinWorkspace(t, ownerToken, workspace, "rbac-viewer@taskmgmt.com", models.RoleViewer)
+
+	_, page := listTasks(t, viewerToken, nil)
+	assert.Equal(t, []string{"Quarterly plan"}, titles(page.Data))
+
+	resp := sendAs(viewerToken, "POST", "/tasks/", map[string]string{"title": "Sneaky edit", "description": "Viewers cannot write"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	var denial struct {
+		Code        string `json:"code"`
+		Permission  string `json:"permission"`
+		Role        string `json:"role"`
+		WorkspaceID uint   `json:"workspace_id"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &denial)
+	assert.Equal(t, "permission_denied", denial.Code)
+	assert.Equal(t, "task:write", denial.Permission)
+	assert.Equal(t, "viewer", denial.Role)
+	assert.Equal(t, workspace.ID, denial.WorkspaceID)
+
+	resp = sendAs(viewerToken, "POST", "/labels/", map[string]string{"name": "nope"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+// Test members write tasks while projects and membership need an admin
+func TestRoles_MemberAndAdmin(t *testing.T) {
+	_, ownerToken := createListingAccount("rbac-boss@taskmgmt.com")
+	workspace := createWorkspace(t, ownerToken, "Engineering")
+	member, memberToken := joinWorkspace(t, ownerToken, workspace, "rbac-member@taskmgmt.com", models.RoleMember)
+
+	resp := sendAs(memberToken, "POST", "/tasks/", map[string]string{"title": "Fix the build", "description": "Members can write"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	resp = sendAs(memberToken, "POST", "/projects/", map[string]string{"name": "Platform"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	resp = sendAs(memberToken, "POST", fmt.Sprintf("/workspaces/%d/invitations", workspace.ID), map[string]string{"email": "rbac-friend@taskmgmt.com"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	// Promoted to admin, the member can run projects and invite
+	resp = sendAs(ownerToken, "PUT", fmt.Sprintf("/workspaces/%d/members/%d", workspace.ID, member.ID), map[string]string{"role": "admin"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(memberToken, "POST", "/projects/", map[string]string{"name": "Platform"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	invite(t, memberToken, workspace, "rbac-friend@taskmgmt.com", models.RoleViewer)
+
+	// Nobody can take the owner's role, and owner is not a role to hand out
+	resp = sendAs(memberToken, "PUT", fmt.Sprintf("/workspaces/%d/members/%d", workspace.ID, workspace.OwnerID), map[string]string{"role": "viewer"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs(ownerToken, "PUT", fmt.Sprintf("/workspaces/%d/members/%d", workspace.ID, member.ID), map[string]string{"role": "owner"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
@@ -0,0 +1,142 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to comment on a task, returning the stored comment
+func postComment(t *testing.T, token string, taskID uint, body string, parentID *uint) models.Comment {
+	resp := sendAs(token, "POST", fmt.Sprintf("/tasks/%d/comments", taskID), controllers.CommentRequest{Body: body, ParentID: parentID})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var comment models.Comment
+	json.Unmarshal(resp.Body.Bytes(), &comment)
+	return comment
+}
+
+// Helper function to fetch one page of a task's comments
+func listComments(t *testing.T, token string, taskID uint, query string) controllers.CommentPage {
+	resp := sendAs(token, "GET", fmt.Sprintf("/tasks/%d/comments?%s", taskID, query), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var page controllers.CommentPage
+	json.Unmarshal(resp.Body.Bytes(), &page)
+	return page
+}
+
+func createCommentedTask(account models.Account, title string) models.Task {
+	task := models.Task{Title: title, Description: "Task to discuss", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID}
+	dao.GetDB().Create(&task)
+	return task
+}
+
+// Test threads list oldest first with their replies, a page at a time
+func TestComments_Threads(t *testing.T) {
+	account, token := createListingAccount("commenter@taskmgmt.com")
+	task := createCommentedTask(account, "Discussed task")
+
+	first := postComment(t, token, task.ID, "**First** thought", nil)
+	assert.Equal(t, "commenter@taskmgmt.com", first.Author.Email)
+	postComment(t, token, task.ID, "Second thought", nil)
+	third := postComment(t, token, task.ID, "Third thought", nil)
+	reply := postComment(t, token, task.ID, "Replying to the first", &first.ID)
+
+	// Replies only go one level deep, and stay on their task
+	resp := sendAs(token, "POST", fmt.Sprintf("/tasks/%d/comments", task.ID), controllers.CommentRequest{Body: "Too deep", ParentID: &reply.ID})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	other := createCommentedTask(account, "Other task")
+	resp = sendAs(token, "POST", fmt.Sprintf("/tasks/%d/comments", other.ID), controllers.CommentRequest{Body: "Wrong task", ParentID: &first.ID})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	page := listComments(t, token, task.ID, "limit=2")
+	assert.Equal(t, int64(3), page.Total)
+	if assert.Len(t, page.Data, 2) && assert.NotNil(t, page.NextCursor) {
+		assert.Equal(t, "**First** thought", page.Data[0].Body)
+		if assert.Len(t, page.Data[0].Replies, 1) {
+			assert.Equal(t, reply.ID, page.Data[0].Replies[0].ID)
+		}
+		page = listComments(t, token, task.ID, "limit=2&cursor="+*page.NextCursor)
+		if assert.Len(t, page.Data, 1) {
+			assert.Equal(t, third.ID, page.Data[0].ID)
+		}
+		assert.Nil(t, page.NextCursor)
+	}
+
+	// Comments of tasks in other workspaces are out of reach
+	_, outsider := createListingAccount("comment-outsider@taskmgmt.com")
+	assert.Equal(t, http.StatusNotFound, sendAs(outsider, "GET", fmt.Sprintf("/tasks/%d/comments", task.ID), nil).Code)
+}
+
+// Test editing keeps the earlier bodies, and only the author may edit
+func TestComments_Edit(t *testing.T) {
+	owner, ownerToken := createListingAccount("comment-editor@taskmgmt.com")
+	workspace := models.Workspace{ID: *owner.ActiveWorkspaceID}
+	_, memberToken := joinWorkspace(t, ownerToken, workspace, "comment-member@taskmgmt.com", models.RoleMember)
+	task := createCommentedTask(owner, "Edited discussion")
+
+	comment := postComment(t, ownerToken, task.ID, "Frist", nil)
+	path := fmt.Sprintf("/tasks/%d/comments/%d", task.ID, comment.ID)
+	resp := sendAs(ownerToken, "PATCH", path, controllers.EditCommentRequest{Body: "First"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &comment)
+	assert.Equal(t, "First", comment.Body)
+	assert.NotNil(t, comment.EditedAt)
+
+	resp = sendAs(memberToken, "PATCH", path, controllers.EditCommentRequest{Body: "Hijacked"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = sendAs(memberToken, "GET", path+"/revisions", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var revisions []models.CommentRevision
+	json.Unmarshal(resp.Body.Bytes(), &revisions)
+	if assert.Len(t, revisions, 1) {
+		assert.Equal(t, "Frist", revisions[0].Body)
+	}
+}
+
+// Test deleting is soft: threads with replies keep a placeholder, and admins moderate
+func TestComments_Delete(t *testing.T) {
+	owner, ownerToken := createListingAccount("comment-moderator@taskmgmt.com")
+	workspace := models.Workspace{ID: *owner.ActiveWorkspaceID}
+	_, memberToken := joinWorkspace(t, ownerToken, workspace, "comment-poster@taskmgmt.com", models.RoleMember)
+	_, viewerToken := joinWorkspace(t, ownerToken, workspace, "comment-viewer@taskmgmt.com", models.RoleViewer)
+	task := createCommentedTask(owner, "Moderated discussion")
+
+	resp := sendAs(viewerToken, "POST", fmt.Sprintf("/tasks/%d/comments", task.ID), controllers.CommentRequest{Body: "Viewers only read"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	thread := postComment(t, memberToken, task.ID, "Start of a thread", nil)
+	postComment(t, ownerToken, task.ID, "A reply", &thread.ID)
+	lonely := postComment(t, memberToken, task.ID, "Nobody answered", nil)
+	ownerComment := postComment(t, ownerToken, task.ID, "From the owner", nil)
+
+	resp = sendAs(memberToken, "DELETE", fmt.Sprintf("/tasks/%d/comments/%d", task.ID, ownerComment.ID), nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	resp = sendAs(memberToken, "DELETE", fmt.Sprintf("/tasks/%d/comments/%d", task.ID, thread.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(ownerToken, "DELETE", fmt.Sprintf("/tasks/%d/comments/%d", task.ID, lonely.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code, "admins can delete anyone's comment")
+	resp = sendAs(ownerToken, "PATCH", fmt.Sprintf("/tasks/%d/comments/%d", task.ID, lonely.ID), controllers.EditCommentRequest{Body: "Back"})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	page := listComments(t, viewerToken, task.ID, "")
+	if assert.Len(t, page.Data, 2) {
+		assert.Equal(t, thread.ID, page.Data[0].ID)
+		assert.Empty(t, page.Data[0].Body)
+		assert.NotNil(t, page.Data[0].DeletedAt)
+		assert.Len(t, page.Data[0].Replies, 1)
+		assert.Equal(t, ownerComment.ID, page.Data[1].ID)
+	}
+
+	// Deleting the task takes its comments with it
+	sendAs(ownerToken, "DELETE", fmt.Sprintf("/tasks/%d", task.ID), nil)
+	var count int64
+	dao.GetDB().Unscoped().Model(&models.Comment{}).Where("task_id = ?", task.ID).Count(&count)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,128 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to make blocker block task through the API
+func addBlocker(token string, task, blocker *models.Task) int {
+	resp := sendAs(token, "POST", fmt.Sprintf("/tasks/%d/blockers", task.ID), controllers.AddBlockerRequest{BlockerID: blocker.ID})
+	return resp.Code
+}
+
+// Test that a task cannot be completed while a blocker is open
+func TestUpdateTask_BlockedCompletion(t *testing.T) {
+	account, token := createListingAccount("blocked@taskmgmt.com")
+	release := createSubtask(account, "blocked-release", nil)
+	tests := createSubtask(account, "blocked-tests", nil)
+	assert.Equal(t, http.StatusCreated, addBlocker(token, release, tests))
+
+	resp := sendAs(token, "PUT", fmt.Sprintf("/tasks/%d", release.ID), map[string]string{"status": "completed"})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	var body struct {
+		Blockers []models.Task `json:"blockers"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.Equal(t, []string{"blocked-tests"}, titles(body.Blockers))
+
+	resp = sendAs(token, "PUT", fmt.Sprintf("/tasks/%d", tests.ID), map[string]string{"status": "completed"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(token, "PUT", fmt.Sprintf("/tasks/%d", release.ID), map[string]string{"status": "completed"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+// ❌ **Test: Dependency Cycles**
+func TestAddBlocker_Cycle(t *testing.T) {
+	account, token := createListingAccount("dependency-cycle@taskmgmt.com")
+	a := createSubtask(account, "cycle-a", nil)
+	b := createSubtask(account, "cycle-b", nil)
+	c := createSubtask(account, "cycle-c", nil)
+	assert.Equal(t, http.StatusCreated, addBlocker(token, a, b))
+	assert.Equal(t, http.StatusCreated, addBlocker(token, b, c))
+
+	assert.Equal(t, http.StatusBadRequest, addBlocker(token, c, a))
+	assert.Equal(t, http.StatusBadRequest, addBlocker(token, a, a))
+}
+
+// Test that opposite dependencies added at once never both go in
+func TestAddBlocker_ConcurrentCycle(t *testing.T) {
+	account, token := createListingAccount("dependency-race@taskmgmt.com")
+	for i := 0; i < 10; i++ {
+		a := createSubtask(account, fmt.Sprintf("race-a-%d", i), nil)
+		b := createSubtask(account, fmt.Sprintf("race-b-%d", i), nil)
+		var wg sync.WaitGroup
+		codes := make([]int, 2)
+		for j, pair := range [][2]*models.Task{{a, b}, {b, a}} {
+			wg.Add(1)
+			go func(j int, task, blocker *models.Task) {
+				defer wg.Done()
+				codes[j] = addBlocker(token, task, blocker)
+			}(j, pair[0], pair[1])
+		}
+		wg.Wait()
+		assert.ElementsMatch(t, []int{http.StatusCreated, http.StatusBadRequest}, codes)
+	}
+	graph, err := models.LoadDependencyGraph(dao.InWorkspace(*account.ActiveWorkspaceID))
+	assert.NoError(t, err)
+	_, err = graph.ExecutionOrder()
+	assert.NoError(t, err)
+}
+
+// ❌ **Test: Blocking on Another Account's Task**
+func TestAddBlocker_OtherAccount(t *testing.T) {
+	other, _ := createListingAccount("dependency-other@taskmgmt.com")
+	theirs := createSubtask(other, "dependency-theirs", nil)
+	mine := createSubtask(testAccount, "dependency-mine", nil)
+
+	assert.Equal(t, http.StatusBadRequest, addBlocker(testToken, mine, theirs))
+}
+
+// Test the dependency graph and its execution order
+func TestDependencyGraphAndOrder(t *testing.T) {
+	account, token := createListingAccount("dependency-order@taskmgmt.com")
+	ship := createSubtask(account, "order-ship", nil)
+	build := createSubtask(account, "order-build", nil)
+	test := createSubtask(account, "order-test", nil)
+	docs := &models.Task{Title: "order-docs", Description: "Urgent docs", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID, Priority: models.PriorityUrgent}
+	dao.GetDB().Create(docs)
+	addBlocker(token, ship, test)
+	addBlocker(token, ship, docs)
+	addBlocker(token, test, build)
+
+	resp := sendAs(token, "GET", "/tasks/dependencies", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var graph models.DependencyGraph
+	json.Unmarshal(resp.Body.Bytes(), &graph)
+	assert.Len(t, graph.Nodes, 4)
+	assert.Len(t, graph.Edges, 3)
+
+	resp = sendAs(token, "GET", "/tasks/dependencies/order", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var order struct {
+		Data []models.Task `json:"data"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &order)
+	assert.Equal(t, []string{"order-docs", "order-build", "order-test", "order-ship"}, titles(order.Data))
+
+	// Removing a dependency and deleting a blocker both drop edges
+	resp = sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d/blockers/%d", ship.ID, docs.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d", build.ID), nil)
+	resp = sendAs(token, "GET", fmt.Sprintf("/tasks/%d/dependencies", test.ID), nil)
+	var deps struct {
+		BlockedBy []models.Task `json:"blocked_by"`
+		Blocks    []models.Task `json:"blocks"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &deps)
+	assert.Empty(t, deps.BlockedBy)
+	assert.Equal(t, []string{"order-ship"}, titles(deps.Blocks))
+}
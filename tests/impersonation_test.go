@@ -0,0 +1,88 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to mint an impersonation token for account as admin
+func impersonate(t *testing.T, adminToken string, accountID uint, request controllers.ImpersonateRequest) string {
+	resp := sendAs(adminToken, "POST", fmt.Sprintf("/admin/accounts/%d/impersonate", accountID), request)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var body struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	return body.Token
+}
+
+// Test support sees what the account sees, read-only, and every request is audited
+func TestImpersonation_ReadOnly(t *testing.T) {
+	admin := loginAdmin(t, "support@taskmgmt.com")
+	account, _ := createListingAccount("cant-see-my-task@taskmgmt.com", "Missing task")
+
+	outsider := login(t, "wannabe-support@taskmgmt.com")
+	resp := sendAs(outsider.Token, "POST", fmt.Sprintf("/admin/accounts/%d/impersonate", account.ID), controllers.ImpersonateRequest{Reason: "curious"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	resp = sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/impersonate", account.ID), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code, "a reason is required")
+
+	token := impersonate(t, admin.Token, account.ID, controllers.ImpersonateRequest{Reason: "ticket #42"})
+	resp = sendAs(token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "support@taskmgmt.com", resp.Header().Get("X-Impersonated-By"))
+	assert.Contains(t, resp.Body.String(), "Missing task")
+
+	resp = sendAs(token, "POST", "/tasks/", map[string]string{"title": "Support was here", "description": "Should not be written"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Contains(t, resp.Body.String(), "impersonation_read_only")
+
+	// The account's own credentials stay out of reach
+	resp = sendAs(token, "GET", "/me/sessions", nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	// Tokens of the account holder are not marked
+	_, ownToken := createListingAccount("not-impersonated@taskmgmt.com")
+	assert.Empty(t, sendAs(ownToken, "GET", "/tasks/", nil).Header().Get("X-Impersonated-By"))
+
+	entries := auditEntries(t, admin.Token, account.ID)
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, models.AuditImpersonatedRequest, entries[0].Action)
+		assert.Equal(t, "GET /me/sessions -> 403", entries[0].Detail)
+		assert.Equal(t, "POST /tasks/ -> 403", entries[1].Detail)
+		assert.Equal(t, "GET /tasks/ -> 200", entries[2].Detail)
+		assert.Equal(t, models.AuditImpersonationStarted, entries[3].Action)
+		assert.Contains(t, entries[3].Detail, "ticket #42")
+	}
+}
+
+// Test writes go through when allowed, and impersonation ends with the admin role
+func TestImpersonation_Writes(t *testing.T) {
+	admin := loginAdmin(t, "support-lead@taskmgmt.com")
+	account, _ := createListingAccount("needs-a-hand@taskmgmt.com")
+
+	token := impersonate(t, admin.Token, account.ID, controllers.ImpersonateRequest{Reason: "fix data", AllowWrites: true, Minutes: 5})
+	resp := sendAs(token, "POST", "/tasks/", map[string]string{"title": "Restored task", "description": "Recreated by support"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, "support-lead@taskmgmt.com", resp.Header().Get("X-Impersonated-By"))
+
+	dao.GetDB().Model(&models.Account{}).Where("email = ?", "support-lead@taskmgmt.com").Update("is_admin", false)
+	assert.Equal(t, http.StatusUnauthorized, sendAs(token, "GET", "/tasks/", nil).Code)
+}
+
+// Test admins cannot be impersonated
+func TestImpersonation_NotAdmins(t *testing.T) {
+	admin := loginAdmin(t, "support-two@taskmgmt.com")
+	other := loginAdmin(t, "support-three@taskmgmt.com")
+	target := getMe(t, other.Token)
+
+	resp := sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/impersonate", target.ID), controllers.ImpersonateRequest{Reason: "escalate"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
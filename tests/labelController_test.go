@@ -0,0 +1,133 @@
+package tests_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to send an authenticated JSON request
+func sendAs(token, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+	return resp
+}
+
+// Helper function to create a label through the API
+func createLabel(t *testing.T, token, name string) models.Label {
+	resp := sendAs(token, "POST", "/labels/", map[string]string{"name": name, "color": "#ff0000"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var label models.Label
+	json.Unmarshal(resp.Body.Bytes(), &label)
+	return label
+}
+
+// Test creating, renaming and deleting a label
+func TestLabelCRUD(t *testing.T) {
+	_, token := createListingAccount("labels@taskmgmt.com")
+	label := createLabel(t, token, "backend")
+	assert.Equal(t, "#ff0000", label.Color)
+
+	resp := sendAs(token, "PUT", fmt.Sprintf("/labels/%d", label.ID), map[string]string{"name": "api"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &label)
+	assert.Equal(t, "api", label.Name)
+	assert.Equal(t, "#ff0000", label.Color)
+
+	resp = sendAs(token, "GET", "/labels/", nil)
+	var labels []models.Label
+	json.Unmarshal(resp.Body.Bytes(), &labels)
+	assert.Len(t, labels, 1)
+
+	resp = sendAs(token, "DELETE", fmt.Sprintf("/labels/%d", label.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+// ❌ **Test: Create Duplicate Label**
+func TestCreateLabel_Duplicate(t *testing.T) {
+	_, token := createListingAccount("label-dupes@taskmgmt.com")
+	createLabel(t, token, "frontend")
+
+	resp := sendAs(token, "POST", "/labels/", map[string]string{"name": "frontend"})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// Another account may use the same name
+	_, otherToken := createListingAccount("label-dupes-other@taskmgmt.com")
+	createLabel(t, otherToken, "frontend")
+}
+
+// ❌ **Test: Create Label with an Invalid Colour**
+func TestCreateLabel_InvalidColor(t *testing.T) {
+	resp := sendAs(testToken, "POST", "/labels/", map[string]string{"name": "colourful", "color": "red"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+// Test attaching labels and filtering the listing with any/all semantics
+func TestLabelAttachAndFilter(t *testing.T) {
+	account, token := createListingAccount("label-filter@taskmgmt.com")
+	bug := createLabel(t, token, "bug")
+	ui := createLabel(t, token, "ui")
+
+	tasks := map[string]*models.Task{}
+	for _, title := range []string{"labelled-both", "labelled-bug", "labelled-none"} {
+		task := &models.Task{Title: title, Description: "Label test task", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID}
+		dao.GetDB().Create(task)
+		tasks[title] = task
+	}
+	attach := func(task *models.Task, label models.Label) *httptest.ResponseRecorder {
+		return sendAs(token, "POST", fmt.Sprintf("/tasks/%d/labels/%d", task.ID, label.ID), nil)
+	}
+	assert.Equal(t, http.StatusOK, attach(tasks["labelled-both"], bug).Code)
+	assert.Equal(t, http.StatusOK, attach(tasks["labelled-both"], ui).Code)
+	resp := attach(tasks["labelled-bug"], bug)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var task models.Task
+	json.Unmarshal(resp.Body.Bytes(), &task)
+	assert.Len(t, task.Labels, 1)
+
+	_, page := listTasks(t, token, url.Values{"label": {"bug,ui"}, "sort": {"title"}})
+	assert.Equal(t, []string{"labelled-both", "labelled-bug"}, titles(page.Data))
+
+	_, page = listTasks(t, token, url.Values{"label": {"bug,ui"}, "label_mode": {"all"}})
+	assert.Equal(t, []string{"labelled-both"}, titles(page.Data))
+	assert.Len(t, page.Data[0].Labels, 2)
+
+	resp = sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d/labels/%d", tasks["labelled-both"].ID, ui.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	_, page = listTasks(t, token, url.Values{"label": {"ui"}})
+	assert.Empty(t, page.Data)
+
+	// Deleting a task removes its label links
+	sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d", tasks["labelled-bug"].ID), nil)
+	var links int64
+	dao.GetDB().Table("task_labels").Where("task_id = ?", tasks["labelled-bug"].ID).Count(&links)
+	assert.Zero(t, links)
+}
+
+// ❌ **Test: Attach Another Account's Label**
+func TestAttachLabel_OtherAccount(t *testing.T) {
+	_, otherToken := createListingAccount("label-owner@taskmgmt.com")
+	label := createLabel(t, otherToken, "secret")
+	task := models.Task{Title: "Wants a label", Description: "Label test task", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
+	dao.GetDB().Create(&task)
+
+	resp := sendAs(testToken, "POST", fmt.Sprintf("/tasks/%d/labels/%d", task.ID, label.ID), nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
@@ -0,0 +1,186 @@
+package tests_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to log in from a given client address
+func loginFrom(ip, email, password string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(controllers.LoginRequest{Email: email, Password: password})
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":40000"
+
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+	return resp
+}
+
+// Helper function to let the current backoff of a throttled key run out
+func expireBackoff(key string) {
+	dao.GetDB().Model(&models.LoginThrottle{}).Where("throttle_key = ?", key).Update("blocked_until", time.Now().Add(-time.Second))
+}
+
+func createVerifiedAccount(email string) *models.Account {
+	account := &models.Account{Email: email, Password: "password123"}
+	account.Create()
+	models.MarkVerified(account.ID, email)
+	return account
+}
+
+// Test repeated wrong passwords back off, then lock the account out
+func TestLoginThrottle_AccountLockout(t *testing.T) {
+	const ip = "198.51.100.10"
+	account := createVerifiedAccount("lockout@taskmgmt.com")
+	key := models.AccountThrottleKey(account.ID)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom(ip, account.Email, "wrong-password").Code)
+	}
+	resp := loginFrom(ip, account.Email, "password123")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+
+	for i := 0; i < 2; i++ {
+		expireBackoff(key)
+		assert.Equal(t, http.StatusUnauthorized, loginFrom(ip, account.Email, "wrong-password").Code)
+	}
+	// Even the right password is refused during a lockout
+	resp = loginFrom(ip, account.Email, "password123")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"locked":true`)
+	assert.True(t, models.AccountLocked(account.ID))
+
+	var failed, locked int64
+	dao.GetDB().Model(&models.AuthEvent{}).Where("account_id = ? AND type = ?", account.ID, models.EventLoginFailed).Count(&failed)
+	dao.GetDB().Model(&models.AuthEvent{}).Where("account_id = ? AND type = ?", account.ID, models.EventAccountLocked).Count(&locked)
+	assert.Equal(t, int64(5), failed)
+	assert.Equal(t, int64(1), locked)
+}
+
+// Test an admin can lift a lockout and read the auth event log
+func TestLoginThrottle_AdminUnlock(t *testing.T) {
+	const ip = "198.51.100.20"
+	account := createVerifiedAccount("unlock-me@taskmgmt.com")
+	for i := 0; i < 5; i++ {
+		expireBackoff(models.AccountThrottleKey(account.ID))
+		loginFrom(ip, account.Email, "wrong-password")
+	}
+	assert.True(t, models.AccountLocked(account.ID))
+
+	outsider := login(t, "not-admin@taskmgmt.com")
+	resp := sendAs(outsider.Token, "POST", fmt.Sprintf("/admin/accounts/%d/unlock", account.ID), nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	admin := login(t, "site-admin@taskmgmt.com")
+	models.GrantAdmin([]string{"site-admin@taskmgmt.com"})
+	resp = sendAs(admin.Token, "POST", fmt.Sprintf("/admin/accounts/%d/unlock", account.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusOK, loginFrom(ip, account.Email, "password123").Code)
+
+	var events []models.AuthEvent
+	resp = sendAs(admin.Token, "GET", fmt.Sprintf("/admin/auth-events?account_id=%d", account.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &events)
+	if assert.NotEmpty(t, events) {
+		// Newest first
+		assert.Equal(t, models.EventLoginSucceeded, events[0].Type)
+		assert.Equal(t, models.EventAccountUnlocked, events[1].Type)
+		assert.Equal(t, ip, events[0].IP)
+	}
+}
+
+// Test one address guessing across many accounts is throttled as a whole
+func TestLoginThrottle_PerIP(t *testing.T) {
+	const ip = "203.0.113.30"
+	account := createVerifiedAccount("stuffing-target@taskmgmt.com")
+
+	for i := 0; i <= models.IPThrottle.FreeFailures; i++ {
+		resp := loginFrom(ip, fmt.Sprintf("stuffed-%d@taskmgmt.com", i), "password123")
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, loginFrom(ip, account.Email, "password123").Code)
+	// Other addresses are unaffected
+	assert.Equal(t, http.StatusOK, loginFrom("203.0.113.31", account.Email, "password123").Code)
+}
+
+// Test failures count up per key and start over once the last one is outside the window
+func TestLoginThrottle_FailureWindow(t *testing.T) {
+	key := models.IPThrottleKey("203.0.113.40")
+	for i := 0; i < 3; i++ {
+		_, err := models.RecordLoginFailure(key, models.IPThrottle)
+		assert.NoError(t, err)
+	}
+	var throttle models.LoginThrottle
+	dao.GetDB().Where("throttle_key = ?", key).Take(&throttle)
+	assert.Equal(t, 3, throttle.Failures)
+
+	dao.GetDB().Model(&throttle).Update("last_failure_at", time.Now().Add(-models.LoginFailureWindow-time.Minute))
+	models.RecordLoginFailure(key, models.IPThrottle)
+	dao.GetDB().Where("throttle_key = ?", key).Take(&throttle)
+	assert.Equal(t, 1, throttle.Failures)
+}
+
+// Helper function to make a signed in request from a given client address
+func sendFrom(ip, token, method, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":40000"
+
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+	return resp
+}
+
+// Test a stolen access token cannot be used to guess the password or a two-factor code
+func TestLoginThrottle_ConfirmationGuesses(t *testing.T) {
+	const ip = "198.51.100.60"
+	session := login(t, "stolen-token@taskmgmt.com")
+	me := getMe(t, session.Token)
+
+	for i := 0; i < 3; i++ {
+		resp := sendFrom(ip, session.Token, "POST", "/password/change", controllers.ChangePasswordRequest{OldPassword: "guess", NewPassword: "taken-over"})
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+	resp := sendFrom(ip, session.Token, "POST", "/password/change", controllers.ChangePasswordRequest{OldPassword: "password123", NewPassword: "taken-over"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	resp = sendFrom(ip, session.Token, "DELETE", "/me", controllers.DeleteAccountRequest{Password: "password123", TaskPolicy: models.TaskPolicyDelete})
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	// The guesses count against the account wherever it signs in from
+	assert.Equal(t, http.StatusTooManyRequests, loginFrom("198.51.100.61", me.Email, "password123").Code)
+
+	// With the password known, codes are throttled the same way
+	other := login(t, "stolen-code@taskmgmt.com")
+	enableTwoFactor(t, other.Token)
+	for i := 0; i < 3; i++ {
+		resp := sendFrom(ip, other.Token, "POST", "/2fa/disable", controllers.DisableTwoFactorRequest{Password: "password123", Code: "000000"})
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+	resp = sendFrom(ip, other.Token, "POST", "/2fa/disable", controllers.DisableTwoFactorRequest{Password: "password123", Code: "000000"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+}
+
+// Test sign-up cannot grant itself admin
+func TestCreateAccount_IgnoresPrivilegedFields(t *testing.T) {
+	resp := sendAs("", "POST", "/account", map[string]interface{}{
+		"email": "sneaky@taskmgmt.com", "password": "password123", "is_admin": true,
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	account := &models.Account{}
+	dao.GetDB().Where("email = ?", "sneaky@taskmgmt.com").First(account)
+	assert.False(t, account.IsAdmin)
+}
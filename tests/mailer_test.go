@@ -0,0 +1,94 @@
+package tests_test
+
+import (
+	"bufio"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"task-management/mailer"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP stands in for an SMTP server, accepting one message and handing its
+// data to the returned channel
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 fake ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 fake")
+			case command == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+// Test the SMTP mailer delivers messages, and user input cannot add headers to them
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	smtpMailer := &mailer.SMTPMailer{Host: host, Port: port, From: "tasks@taskmgmt.com"}
+
+	err := smtpMailer.Send(mailer.Message{
+		To:      "invitee@taskmgmt.com",
+		Subject: "Join x\r\nContent-Type: text/html\r\n\r\n<h1>phishing</h1>",
+		Body:    "You have been invited.\n",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	message, err := mail.ReadMessage(strings.NewReader(<-received))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"text/plain; charset=UTF-8"}, message.Header["Content-Type"])
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Join x\r\nContent-Type: text/html\r\n\r\n<h1>phishing</h1>", subject)
+
+	err = smtpMailer.Send(mailer.Message{To: "invitee@taskmgmt.com\r\nBcc: everyone@taskmgmt.com", Subject: "Hi"})
+	assert.ErrorIs(t, err, mailer.ErrInvalidHeader)
+}
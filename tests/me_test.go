@@ -0,0 +1,146 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to read the caller's account
+func getMe(t *testing.T, token string) models.Account {
+	resp := sendAs(token, "GET", "/me", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var body struct {
+		Account models.Account `json:"account"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	return body.Account
+}
+
+// Test the profile can be read and partly updated, and is validated
+func TestMe_Profile(t *testing.T) {
+	session := login(t, "profile@taskmgmt.com")
+	me := getMe(t, session.Token)
+	assert.Equal(t, "profile@taskmgmt.com", me.Email)
+	assert.Empty(t, me.Password)
+	assert.NotContains(t, sendAs(session.Token, "GET", "/me", nil).Body.String(), `"password":`)
+
+	resp := sendAs(session.Token, "PATCH", "/me", map[string]string{"display_name": "Ada", "timezone": "Europe/Paris"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(session.Token, "PATCH", "/me", map[string]string{"avatar_url": "https://example.com/ada.png"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	me = getMe(t, session.Token)
+	assert.Equal(t, "Ada", me.DisplayName)
+	assert.Equal(t, "Europe/Paris", me.Timezone)
+	assert.Equal(t, "https://example.com/ada.png", me.AvatarURL)
+
+	for _, invalid := range []map[string]string{
+		{"timezone": "Mars/Olympus_Mons"},
+		{"avatar_url": "javascript:alert(1)"},
+		{"display_name": strings.Repeat("a", models.MaxDisplayNameLength+1)},
+	} {
+		resp = sendAs(session.Token, "PATCH", "/me", invalid)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, invalid)
+	}
+	assert.Equal(t, "Europe/Paris", getMe(t, session.Token).Timezone)
+}
+
+// Test sessions can be listed and one revoked without signing out the others
+func TestMe_Sessions(t *testing.T) {
+	phone := login(t, "sessions@taskmgmt.com")
+	laptop := loginAs(t, "sessions@taskmgmt.com", "password123")
+
+	resp := sendAs(laptop.Token, "GET", "/me/sessions", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var sessions []struct {
+		ID      string `json:"id"`
+		Current bool   `json:"current"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &sessions)
+	if !assert.Len(t, sessions, 2) {
+		return
+	}
+	var phoneSession string
+	for _, session := range sessions {
+		if !session.Current {
+			phoneSession = session.ID
+		}
+	}
+	assert.NotEmpty(t, phoneSession)
+
+	// Other accounts' sessions cannot be touched
+	other := login(t, "sessions-other@taskmgmt.com")
+	resp = sendAs(other.Token, "DELETE", "/me/sessions/"+phoneSession, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = sendAs(laptop.Token, "DELETE", "/me/sessions/"+phoneSession, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusUnauthorized, sendAs(phone.Token, "GET", "/me", nil).Code)
+	code, _ := refresh(phone.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, http.StatusOK, sendAs(laptop.Token, "GET", "/me", nil).Code)
+}
+
+// Test deleting an account takes the password and cleans up after it
+func TestMe_Delete(t *testing.T) {
+	session := login(t, "leaver@taskmgmt.com")
+	sendAs(session.Token, "POST", "/tasks/", map[string]string{"title": "Private notes", "description": "Only mine"})
+	leaver := getMe(t, session.Token)
+
+	resp := sendAs(session.Token, "DELETE", "/me", controllers.DeleteAccountRequest{Password: "wrong-password", TaskPolicy: models.TaskPolicyDelete})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = sendAs(session.Token, "DELETE", "/me", controllers.DeleteAccountRequest{Password: "password123", TaskPolicy: "keep"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = sendAs(session.Token, "DELETE", "/me", controllers.DeleteAccountRequest{Password: "password123", TaskPolicy: models.TaskPolicyDelete})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusUnauthorized, sendAs(session.Token, "GET", "/me", nil).Code)
+	code, _ := refresh(session.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// The account row is gone, not just hidden
+	var count int64
+	dao.GetDB().Unscoped().Model(&models.Account{}).Where("id = ? OR email = ?", leaver.ID, leaver.Email).Count(&count)
+	assert.Zero(t, count)
+
+	// The personal workspace went with the account
+	dao.GetDB().Model(&models.Task{}).Where("account_id = ?", leaver.ID).Count(&count)
+	assert.Zero(t, count)
+	dao.GetDB().Model(&models.Workspace{}).Where("id = ?", *leaver.ActiveWorkspaceID).Count(&count)
+	assert.Zero(t, count)
+}
+
+// Test a deleted owner's shared workspace and tasks pass to the next admin
+func TestMe_DeleteReassigns(t *testing.T) {
+	session := login(t, "departing-owner@taskmgmt.com")
+	workspace := createWorkspace(t, session.Token, "Handover")
+	sendAs(session.Token, "POST", fmt.Sprintf("/workspaces/%d/activate", workspace.ID), nil)
+	resp := sendAs(session.Token, "POST", "/tasks/", map[string]string{"title": "Keep the lights on", "description": "Survives its creator"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	_, memberToken := joinWorkspace(t, session.Token, workspace, "handover-member@taskmgmt.com", models.RoleMember)
+	admin, adminToken := joinWorkspace(t, session.Token, workspace, "handover-admin@taskmgmt.com", models.RoleAdmin)
+
+	resp = sendAs(session.Token, "DELETE", "/me", controllers.DeleteAccountRequest{Password: "password123", TaskPolicy: models.TaskPolicyReassign})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// The admin outranks the member who joined first
+	var handedOver models.Workspace
+	dao.GetDB().First(&handedOver, workspace.ID)
+	assert.Equal(t, admin.ID, handedOver.OwnerID)
+	assert.False(t, handedOver.Personal)
+	assert.True(t, models.IsMember(workspace.ID, admin.ID))
+
+	_, page := listTasks(t, memberToken, nil)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, admin.ID, page.Data[0].AccountID)
+	}
+	resp = sendAs(adminToken, "POST", fmt.Sprintf("/workspaces/%d/invitations", workspace.ID), map[string]string{"email": "handover-new@taskmgmt.com"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+}
@@ -0,0 +1,318 @@
+package tests_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"task-management/dao"
+	"task-management/jwtkeys"
+	"task-management/models"
+	"task-management/oidc"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// A user signing in at the mock identity provider
+type idpUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type idpGrant struct {
+	user      idpUser
+	nonce     string
+	challenge string
+}
+
+// mockIdP is a minimal OpenID provider: discovery, a token endpoint checking
+// PKCE and client credentials, and a key set. Sign ins are approved directly.
+type mockIdP struct {
+	*httptest.Server
+	name string
+	key  *rsa.PrivateKey
+
+	mu       sync.Mutex
+	grants   map[string]idpGrant
+	audience string          // Overrides the aud claim when set
+	signWith *rsa.PrivateKey // Overrides the signing key when set
+}
+
+func newMockIdP(t *testing.T, name string) *mockIdP {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp := &mockIdP{name: name, key: key, grants: map[string]idpGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "use": "sig", "alg": "RS256", "kid": "mock-key",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	oidc.Register(&oidc.Provider{
+		Name:         name,
+		Issuer:       idp.URL,
+		ClientID:     "task-management",
+		ClientSecret: "mock-secret",
+		RedirectURL:  "http://localhost:8080/auth/oidc/" + name + "/callback",
+	})
+	return idp
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	client, secret, _ := r.BasicAuth()
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.FormValue("code")]
+	delete(idp.grants, r.FormValue("code"))
+	idp.mu.Unlock()
+	if client != "task-management" || secret != "mock-secret" || !ok || oidc.Challenge(r.FormValue("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	audience, signer := "task-management", idp.key
+	if idp.audience != "" {
+		audience = idp.audience
+	}
+	if idp.signWith != nil {
+		signer = idp.signWith
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            grant.user.Subject,
+		"aud":            audience,
+		"email":          grant.user.Email,
+		"email_verified": grant.user.EmailVerified,
+		"nonce":          grant.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	idToken.Header["kid"] = "mock-key"
+	signed, _ := idToken.SignedString(signer)
+	json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "mock-access", "token_type": "Bearer", "id_token": signed, "expires_in": 3600})
+}
+
+// approve signs user in at the authorization URL and returns the callback the
+// provider would redirect the browser to
+func (idp *mockIdP) approve(t *testing.T, authorizationURL string, user idpUser) string {
+	target, err := url.Parse(authorizationURL)
+	assert.NoError(t, err)
+	query := target.Query()
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Contains(t, query.Get("scope"), "openid")
+
+	code := models.RandomToken(16)
+	idp.mu.Lock()
+	idp.grants[code] = idpGrant{user: user, nonce: query.Get("nonce"), challenge: query.Get("code_challenge")}
+	idp.mu.Unlock()
+	return "/auth/oidc/" + idp.name + "/callback?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+}
+
+// Helper function to follow the provider's redirect to callback from the
+// browser whose request got the started response
+func returnTo(started *httptest.ResponseRecorder, callback string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", callback, nil)
+	for _, cookie := range started.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+	return resp
+}
+
+// Helper function to sign in through the mock provider
+func ssoLogin(t *testing.T, idp *mockIdP, user idpUser) *httptest.ResponseRecorder {
+	resp := sendAs("", "GET", "/auth/oidc/"+idp.name+"/login", nil)
+	assert.Equal(t, http.StatusFound, resp.Code)
+	return returnTo(resp, idp.approve(t, resp.Header().Get("Location"), user))
+}
+
+type ssoResponse struct {
+	Token          string `json:"token"`
+	AccountCreated bool   `json:"account_created"`
+}
+
+// Test a first sign in provisions a verified account, later ones reuse it
+func TestOIDC_Provisioning(t *testing.T) {
+	idp := newMockIdP(t, "mock-provision")
+	user := idpUser{Subject: "user-1", Email: "sso-new@taskmgmt.com", EmailVerified: true}
+
+	var first, second ssoResponse
+	resp := ssoLogin(t, idp, user)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &first)
+	assert.True(t, first.AccountCreated)
+	assert.Equal(t, http.StatusOK, sendAs(first.Token, "GET", "/tasks/", nil).Code)
+
+	account := &models.Account{}
+	dao.GetDB().Where("email = ?", user.Email).First(account)
+	assert.True(t, account.Verified())
+
+	resp = ssoLogin(t, idp, user)
+	json.Unmarshal(resp.Body.Bytes(), &second)
+	assert.False(t, second.AccountCreated)
+	var count int64
+	dao.GetDB().Model(&models.Account{}).Where("email = ?", user.Email).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	resp = ssoLogin(t, idp, idpUser{Subject: "user-2", Email: "sso-unverified@taskmgmt.com"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+// Test a verified email signs in to the existing account with that address
+func TestOIDC_LinksExistingAccount(t *testing.T) {
+	idp := newMockIdP(t, "mock-existing")
+	existing := createVerifiedAccount("sso-existing@taskmgmt.com")
+
+	var body ssoResponse
+	resp := ssoLogin(t, idp, idpUser{Subject: "existing-1", Email: existing.Email, EmailVerified: true})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.False(t, body.AccountCreated)
+	assert.Equal(t, http.StatusOK, loginFrom("198.51.100.40", existing.Email, "password123").Code)
+
+	// Someone who signed up with the address but never verified it loses the account
+	squatter := &models.Account{Email: "sso-squatted@taskmgmt.com", Password: "password123"}
+	squatter.Create()
+	resp = ssoLogin(t, idp, idpUser{Subject: "squatted-1", Email: squatter.Email, EmailVerified: true})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusUnauthorized, loginFrom("198.51.100.40", squatter.Email, "password123").Code)
+}
+
+// Test a signed in user can link an identity with a different email, then sign in with it
+func TestOIDC_ExplicitLink(t *testing.T) {
+	idp := newMockIdP(t, "mock-link")
+	session := login(t, "sso-linker@taskmgmt.com")
+	user := idpUser{Subject: "work-account", Email: "linker@corp.example", EmailVerified: true}
+
+	var started struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	link := sendAs(session.Token, "POST", "/auth/oidc/mock-link/link", nil)
+	assert.Equal(t, http.StatusOK, link.Code)
+	json.Unmarshal(link.Body.Bytes(), &started)
+	resp := returnTo(link, idp.approve(t, started.AuthorizationURL, user))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var identities []models.AccountIdentity
+	resp = sendAs(session.Token, "GET", "/auth/oidc/identities", nil)
+	json.Unmarshal(resp.Body.Bytes(), &identities)
+	if assert.Len(t, identities, 1) {
+		assert.Equal(t, "work-account", identities[0].Subject)
+	}
+
+	var body ssoResponse
+	resp = ssoLogin(t, idp, user)
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	claims, _ := jwtkeys.GetKeyring().Parse(body.Token)
+	assert.Equal(t, "sso-linker@taskmgmt.com", claims["username"])
+
+	// The identity cannot also be linked to someone else
+	other := login(t, "sso-other@taskmgmt.com")
+	link = sendAs(other.Token, "POST", "/auth/oidc/mock-link/link", nil)
+	json.Unmarshal(link.Body.Bytes(), &started)
+	resp = returnTo(link, idp.approve(t, started.AuthorizationURL, user))
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = sendAs(session.Token, "DELETE", "/auth/oidc/mock-link/link", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+// Test responses that do not belong to the sign in attempt are refused
+func TestOIDC_RejectsBadResponses(t *testing.T) {
+	idp := newMockIdP(t, "mock-reject")
+	user := idpUser{Subject: "reject-1", Email: "sso-reject@taskmgmt.com", EmailVerified: true}
+
+	started := sendAs("", "GET", "/auth/oidc/mock-reject/login", nil)
+	callback := idp.approve(t, started.Header().Get("Location"), user)
+	assert.Equal(t, http.StatusOK, returnTo(started, callback).Code)
+	// A state only works once
+	assert.Equal(t, http.StatusBadRequest, returnTo(started, callback).Code)
+
+	idp.audience = "another-client"
+	assert.Equal(t, http.StatusUnauthorized, ssoLogin(t, idp, user).Code)
+	idp.audience = ""
+
+	forger, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp.signWith = forger
+	assert.Equal(t, http.StatusUnauthorized, ssoLogin(t, idp, user).Code)
+	idp.signWith = nil
+
+	resp := sendAs("", "GET", "/auth/oidc/mock-reject/callback?error=access_denied&state=x", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Equal(t, http.StatusNotFound, sendAs("", "GET", "/auth/oidc/nobody/login", nil).Code)
+
+	var providers struct {
+		Providers []string `json:"providers"`
+	}
+	json.Unmarshal(sendAs("", "GET", "/auth/oidc/providers", nil).Body.Bytes(), &providers)
+	assert.Contains(t, providers.Providers, "mock-reject")
+}
+
+// Test a sign in or link only completes in the browser that started it
+func TestOIDC_StateBoundToBrowser(t *testing.T) {
+	idp := newMockIdP(t, "mock-bound")
+	attacker := idpUser{Subject: "attacker-1", Email: "sso-attacker@taskmgmt.com", EmailVerified: true}
+
+	// An attacker's sign in, replayed in a victim's browser, does not log the victim in as them
+	started := sendAs("", "GET", "/auth/oidc/mock-bound/login", nil)
+	if assert.Len(t, started.Result().Cookies(), 1) {
+		cookie := started.Result().Cookies()[0]
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.Equal(t, "/auth/oidc/mock-bound/callback", cookie.Path)
+	}
+	callback := idp.approve(t, started.Header().Get("Location"), attacker)
+	assert.Equal(t, http.StatusBadRequest, sendAs("", "GET", callback, nil).Code)
+	victim := sendAs("", "GET", "/auth/oidc/mock-bound/login", nil)
+	assert.Equal(t, http.StatusBadRequest, returnTo(victim, callback).Code)
+	// Refusing the replay leaves the attacker's own attempt usable
+	assert.Equal(t, http.StatusOK, returnTo(started, callback).Code)
+
+	// Nor does it link the attacker's identity to the victim's account
+	session := login(t, "sso-bound@taskmgmt.com")
+	var link struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	json.Unmarshal(sendAs(session.Token, "POST", "/auth/oidc/mock-bound/link", nil).Body.Bytes(), &link)
+	callback = idp.approve(t, link.AuthorizationURL, idpUser{Subject: "attacker-2", Email: "sso-attacker2@taskmgmt.com", EmailVerified: true})
+	assert.Equal(t, http.StatusBadRequest, sendAs("", "GET", callback, nil).Code)
+	var identities []models.AccountIdentity
+	json.Unmarshal(sendAs(session.Token, "GET", "/auth/oidc/identities", nil).Body.Bytes(), &identities)
+	assert.Empty(t, identities)
+}
+
+// Test sign ins do not skip the account's two-factor login
+func TestOIDC_TwoFactor(t *testing.T) {
+	idp := newMockIdP(t, "mock-2fa")
+	session := login(t, "sso-2fa@taskmgmt.com")
+	enableTwoFactor(t, session.Token)
+
+	resp := ssoLogin(t, idp, idpUser{Subject: "2fa-1", Email: "sso-2fa@taskmgmt.com", EmailVerified: true})
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Contains(t, resp.Body.String(), "challenge_token")
+	assert.NotContains(t, resp.Body.String(), `"token"`)
+}
@@ -0,0 +1,83 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"task-management/controllers"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Reset tokens sit on a line of their own in the email
+var resetTokenPattern = regexp.MustCompile(`(?m)^[\w-]{32,}$`)
+
+// Helper function to request a reset email, returning the token in it
+func forgotPassword(t *testing.T, email string) string {
+	sent := captureMail(t, func() {
+		resp := sendAs("", "POST", "/password/forgot", controllers.ForgotPasswordRequest{Email: email})
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+	if len(sent) == 0 {
+		return ""
+	}
+	assert.Equal(t, email, sent[0].To)
+	return resetTokenPattern.FindString(sent[0].Body)
+}
+
+// Test resetting a forgotten password through the emailed token
+func TestResetPassword(t *testing.T) {
+	session := login(t, "forgot@taskmgmt.com")
+	token := forgotPassword(t, "forgot@taskmgmt.com")
+	assert.NotEmpty(t, token)
+
+	resp := sendAs("", "POST", "/password/reset", controllers.ResetPasswordRequest{Token: token, Password: "short"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs("", "POST", "/password/reset", controllers.ResetPasswordRequest{Token: token, Password: strings.Repeat("long", 19)})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	// A password that cannot be set leaves the token unused
+	assert.Error(t, models.ResetPassword(token, strings.Repeat("long", 19)))
+	resp = sendAs("", "POST", "/password/reset", controllers.ResetPasswordRequest{Token: token, Password: "new-password"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// The token is single use, and the old password and sessions are gone
+	resp = sendAs("", "POST", "/password/reset", controllers.ResetPasswordRequest{Token: token, Password: "other-password"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs(session.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = sendAs("", "POST", "/login", controllers.LoginRequest{Email: "forgot@taskmgmt.com", Password: "password123"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	loginAs(t, "forgot@taskmgmt.com", "new-password")
+}
+
+// Test unknown addresses get the same answer but no email
+func TestForgotPassword_UnknownEmail(t *testing.T) {
+	assert.Empty(t, forgotPassword(t, "nobody@taskmgmt.com"))
+}
+
+// Test changing the password needs the old one and signs out other sessions
+func TestChangePassword(t *testing.T) {
+	session := login(t, "change@taskmgmt.com")
+	laptop := loginAs(t, "change@taskmgmt.com", "password123")
+
+	resp := sendAs(session.Token, "POST", "/password/change", controllers.ChangePasswordRequest{OldPassword: "wrong-password", NewPassword: "new-password"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = sendAs(session.Token, "POST", "/password/change", controllers.ChangePasswordRequest{OldPassword: "password123", NewPassword: "new-password"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var fresh sessionTokens
+	json.Unmarshal(resp.Body.Bytes(), &fresh)
+
+	for _, token := range []string{session.Token, laptop.Token} {
+		resp = sendAs(token, "GET", "/tasks/", nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+	code, _ := refresh(laptop.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	resp = sendAs(fresh.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	loginAs(t, "change@taskmgmt.com", "new-password")
+}
@@ -0,0 +1,100 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to create a project through the API
+func createProject(t *testing.T, token string, body map[string]string) models.Project {
+	resp := sendAs(token, "POST", "/projects/", body)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var project models.Project
+	json.Unmarshal(resp.Body.Bytes(), &project)
+	return project
+}
+
+// Test creating tasks in a project with its default status
+func TestProjectTasks(t *testing.T) {
+	_, token := createListingAccount("projects@taskmgmt.com")
+	project := createProject(t, token, map[string]string{"name": "Website", "default_status": "in-progress"})
+
+	resp := sendAs(token, "POST", fmt.Sprintf("/projects/%d/tasks", project.ID), map[string]string{"title": "Homepage", "description": "Build the homepage"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var task models.Task
+	json.Unmarshal(resp.Body.Bytes(), &task)
+	assert.Equal(t, project.ID, *task.ProjectID)
+	assert.Equal(t, models.StatusInProgress, task.Status)
+
+	resp = sendAs(token, "GET", fmt.Sprintf("/projects/%d/tasks", project.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	_, page := listTasks(t, token, url.Values{"project_id": {fmt.Sprint(project.ID)}})
+	assert.Equal(t, []string{"Homepage"}, titles(page.Data))
+}
+
+// Test that task titles only need to be unique within a project
+func TestProjectTasks_TitleScopedToProject(t *testing.T) {
+	_, token := createListingAccount("project-titles@taskmgmt.com")
+	web := createProject(t, token, map[string]string{"name": "Web"})
+	mobile := createProject(t, token, map[string]string{"name": "Mobile"})
+	body := map[string]string{"title": "Release", "description": "Ship the release"}
+
+	resp := sendAs(token, "POST", fmt.Sprintf("/projects/%d/tasks", web.ID), body)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	resp = sendAs(token, "POST", fmt.Sprintf("/projects/%d/tasks", mobile.ID), body)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	resp = sendAs(token, "POST", fmt.Sprintf("/projects/%d/tasks", web.ID), body)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+// Test archiving hides a project and stops new tasks until it is unarchived
+func TestArchiveProject(t *testing.T) {
+	_, token := createListingAccount("project-archive@taskmgmt.com")
+	project := createProject(t, token, map[string]string{"name": "Legacy"})
+	body := map[string]string{"title": "Maintenance", "description": "Keep the lights on"}
+
+	resp := sendAs(token, "POST", fmt.Sprintf("/projects/%d/archive", project.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var projects []models.Project
+	resp = sendAs(token, "GET", "/projects/", nil)
+	json.Unmarshal(resp.Body.Bytes(), &projects)
+	assert.Empty(t, projects)
+	resp = sendAs(token, "GET", "/projects/?archived=true", nil)
+	json.Unmarshal(resp.Body.Bytes(), &projects)
+	assert.Len(t, projects, 1)
+
+	resp = sendAs(token, "POST", fmt.Sprintf("/projects/%d/tasks", project.ID), body)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	sendAs(token, "POST", fmt.Sprintf("/projects/%d/unarchive", project.ID), nil)
+	resp = sendAs(token, "POST", fmt.Sprintf("/projects/%d/tasks", project.ID), body)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// A project with tasks cannot be deleted
+	resp = sendAs(token, "DELETE", fmt.Sprintf("/projects/%d", project.ID), nil)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+// ❌ **Test: Project Validation and Ownership**
+func TestProject_Invalid(t *testing.T) {
+	_, token := createListingAccount("project-invalid@taskmgmt.com")
+	resp := sendAs(token, "POST", "/projects/", map[string]string{"name": "Bad", "default_status": "someday"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	project := createProject(t, token, map[string]string{"name": "Mine"})
+	resp = sendAs(token, "POST", "/projects/", map[string]string{"name": "Mine"})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = sendAs(testToken, "GET", fmt.Sprintf("/projects/%d", project.ID), nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	resp = sendAs(testToken, "POST", "/tasks/", map[string]interface{}{"title": "Sneaky", "description": "Not my project", "project_id": project.ID})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
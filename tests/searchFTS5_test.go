@@ -0,0 +1,10 @@
+//go:build sqlite_fts5
+
+package tests_test
+
+import "task-management/dao"
+
+// The SQLite driver is compiled with FTS5, so search must run on its index
+func init() {
+	wantSearchBackend = dao.SearchFTS5
+}
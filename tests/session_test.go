@@ -0,0 +1,97 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"task-management/controllers"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Helper function to create an account and log it in
+func login(t *testing.T, email string) sessionTokens {
+	account := &models.Account{Email: email, Password: "password123"}
+	account.Create()
+	models.MarkVerified(account.ID, email)
+	return loginAs(t, email, "password123")
+}
+
+func loginAs(t *testing.T, email, password string) sessionTokens {
+	resp := sendAs("", "POST", "/login", controllers.LoginRequest{Email: email, Password: password})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var tokens sessionTokens
+	json.Unmarshal(resp.Body.Bytes(), &tokens)
+	return tokens
+}
+
+// Helper function to exchange a refresh token
+func refresh(refreshToken string) (int, sessionTokens) {
+	resp := sendAs("", "POST", "/token/refresh", controllers.RefreshRequest{RefreshToken: refreshToken})
+	var tokens sessionTokens
+	json.Unmarshal(resp.Body.Bytes(), &tokens)
+	return resp.Code, tokens
+}
+
+// Test logging in issues a short-lived access token that can be refreshed
+func TestRefreshToken(t *testing.T) {
+	session := login(t, "refresh@taskmgmt.com")
+	assert.NotEmpty(t, session.RefreshToken)
+	assert.Equal(t, int(models.AccessTokenLifetime.Seconds()), session.ExpiresIn)
+
+	code, renewed := refresh(session.RefreshToken)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, session.RefreshToken, renewed.RefreshToken)
+	resp := sendAs(renewed.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	code, _ = refresh("not-a-refresh-token")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+// ❌ **Test: Reusing a Rotated Refresh Token Revokes the Session**
+func TestRefreshToken_Reuse(t *testing.T) {
+	session := login(t, "refresh-reuse@taskmgmt.com")
+	_, renewed := refresh(session.RefreshToken)
+
+	code, _ := refresh(session.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Everything issued in the session stops working
+	code, _ = refresh(renewed.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	resp := sendAs(renewed.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+// Test logging out revokes one session, or all of them
+func TestLogout(t *testing.T) {
+	session := login(t, "logout@taskmgmt.com")
+	other := loginAs(t, "logout@taskmgmt.com", "password123")
+
+	resp := sendAs(session.Token, "POST", "/logout", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(session.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	code, _ := refresh(session.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// The other device is still signed in until it logs out everywhere
+	resp = sendAs(other.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	third := loginAs(t, "logout@taskmgmt.com", "password123")
+	resp = sendAs(other.Token, "POST", "/logout?all=true", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(third.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	code, _ = refresh(third.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
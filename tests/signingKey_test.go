@@ -0,0 +1,146 @@
+package tests_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"task-management/dao"
+	"task-management/jwtkeys"
+	"task-management/models"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to fetch the published key set, as another service would
+func fetchJWKS(t *testing.T) map[string]jwtkeys.JWK {
+	resp := sendAs("", "GET", "/.well-known/jwks.json", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var set struct {
+		Keys []jwtkeys.JWK `json:"keys"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &set)
+
+	keys := map[string]jwtkeys.JWK{}
+	for _, key := range set.Keys {
+		keys[key.Kid] = key
+	}
+	return keys
+}
+
+// verifyWithJWKS checks a token using only the published public keys
+func verifyWithJWKS(t *testing.T, token string) (jwt.MapClaims, error) {
+	jwks := fetchJWKS(t)
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		jwk := jwks[token.Header["kid"].(string)]
+		switch jwk.Kty {
+		case "RSA":
+			n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+			return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+		case "OKP":
+			x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+			return ed25519.PublicKey(x), nil
+		}
+		return nil, jwt.ErrInvalidKey
+	})
+	return claims, err
+}
+
+func tokenKid(token string) string {
+	parsed, _, _ := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+// Test issued tokens can be verified from the JWKS endpoint alone
+func TestSigningKeys_JWKS(t *testing.T) {
+	session := login(t, "jwks@taskmgmt.com")
+	assert.NotEmpty(t, tokenKid(session.Token))
+
+	claims, err := verifyWithJWKS(t, session.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "jwks@taskmgmt.com", claims["username"])
+	for _, jwk := range fetchJWKS(t) {
+		assert.NotEmpty(t, jwk.N+jwk.X)
+		assert.Equal(t, "sig", jwk.Use)
+	}
+}
+
+// Test rotation keeps old tokens valid until the retired key expires
+func TestSigningKeys_Rotation(t *testing.T) {
+	// A key of its own, so expiring it leaves other tests' tokens alone
+	_, err := models.RotateSigningKey(jwtkeys.RS256)
+	assert.NoError(t, err)
+	assert.NoError(t, jwtkeys.GetKeyring().Refresh())
+	before := login(t, "rotation@taskmgmt.com")
+	oldKid := tokenKid(before.Token)
+
+	_, err = models.RotateSigningKey(jwtkeys.EdDSA)
+	assert.NoError(t, err)
+	assert.NoError(t, jwtkeys.GetKeyring().Refresh())
+	defer func() {
+		models.RotateSigningKey(jwtkeys.RS256)
+		jwtkeys.GetKeyring().Refresh()
+	}()
+
+	after := loginAs(t, "rotation@taskmgmt.com", "password123")
+	assert.NotEqual(t, oldKid, tokenKid(after.Token))
+	_, err = verifyWithJWKS(t, after.Token)
+	assert.NoError(t, err, "EdDSA tokens verify from the JWKS")
+	assert.Equal(t, http.StatusOK, sendAs(after.Token, "GET", "/tasks/", nil).Code)
+	assert.Equal(t, http.StatusOK, sendAs(before.Token, "GET", "/tasks/", nil).Code)
+	assert.Contains(t, fetchJWKS(t), oldKid)
+
+	// Once the retired key expires its tokens stop working and it is unpublished
+	dao.GetDB().Model(&models.SigningKey{}).Where("kid = ?", oldKid).Update("expires_at", time.Now().Add(-time.Second))
+	assert.NoError(t, jwtkeys.GetKeyring().Refresh())
+	assert.Equal(t, http.StatusUnauthorized, sendAs(before.Token, "GET", "/tasks/", nil).Code)
+	assert.NotContains(t, fetchJWKS(t), oldKid)
+}
+
+// Test a rotation racing another instance's never retires the newer key
+func TestSigningKeys_ConcurrentRotation(t *testing.T) {
+	// The key another instance created a moment after ours, but stored first
+	newer := models.SigningKey{KID: "other-instance", Algorithm: jwtkeys.RS256, PrivateKey: []byte("sealed elsewhere"), CreatedAt: time.Now().Add(time.Second)}
+	dao.GetDB().Create(&newer)
+	defer func() {
+		dao.GetDB().Delete(&newer)
+		jwtkeys.GetKeyring().Refresh()
+	}()
+
+	key, err := models.RotateSigningKey(jwtkeys.RS256)
+	assert.NoError(t, err)
+	for _, kid := range []string{key.ID, newer.KID} {
+		var stored models.SigningKey
+		dao.GetDB().First(&stored, "kid = ?", kid)
+		assert.Nil(t, stored.RetiredAt, kid)
+	}
+}
+
+// Test tokens are only accepted with the algorithm of the key they name
+func TestSigningKeys_RejectsForgedTokens(t *testing.T) {
+	current, err := jwtkeys.GetKeyring().Current()
+	assert.NoError(t, err)
+	claims := jwt.MapClaims{"username": "testuser", "jti": models.RandomToken(16), "exp": time.Now().Add(time.Hour).Unix()}
+
+	// HMAC keyed with the published public key
+	jwk := fetchJWKS(t)[current.ID]
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = current.ID
+	signed, _ := forged.SignedString([]byte(jwk.N))
+	assert.Equal(t, http.StatusUnauthorized, sendAs(signed, "GET", "/tasks/", nil).Code)
+
+	// The old shared secret no longer signs tokens
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenVal))
+	assert.Equal(t, http.StatusUnauthorized, sendAs(legacy, "GET", "/tasks/", nil).Code)
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.Equal(t, http.StatusUnauthorized, sendAs(unsigned, "GET", "/tasks/", nil).Code)
+}
@@ -0,0 +1,139 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to create a task under parent for account
+func createSubtask(account models.Account, title string, parent *models.Task) *models.Task {
+	task := &models.Task{Title: title, Description: "Subtask test task", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID}
+	if parent != nil {
+		task.ParentID = &parent.ID
+	}
+	dao.GetDB().Create(task)
+	return task
+}
+
+// Test fetching an epic with its nested steps
+func TestGetSubtree(t *testing.T) {
+	account, token := createListingAccount("subtree@taskmgmt.com")
+	epic := createSubtask(account, "tree-epic", nil)
+	step := createSubtask(account, "tree-step", epic)
+	createSubtask(account, "tree-substep", step)
+	createSubtask(account, "tree-other-step", epic)
+
+	resp := sendAs(token, "GET", fmt.Sprintf("/tasks/%d/subtree", epic.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var tree models.TaskNode
+	json.Unmarshal(resp.Body.Bytes(), &tree)
+	assert.Equal(t, "tree-epic", tree.Title)
+	if assert.Len(t, tree.Children, 2) {
+		assert.Equal(t, "tree-step", tree.Children[0].Title)
+		assert.Len(t, tree.Children[0].Children, 1)
+		assert.Empty(t, tree.Children[1].Children)
+	}
+}
+
+// Test creating a subtask through the API
+func TestCreateTask_WithParent(t *testing.T) {
+	account, token := createListingAccount("subtask-create@taskmgmt.com")
+	epic := createSubtask(account, "create-epic", nil)
+
+	resp := sendAs(token, "POST", "/tasks/", map[string]interface{}{"title": "create-step", "description": "A step of the epic", "parent_id": epic.ID})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Another account's task cannot be used as parent
+	resp = sendAs(testToken, "POST", "/tasks/", map[string]interface{}{"title": "foreign-step", "description": "A step of the epic", "parent_id": epic.ID})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+// Test moving a subtree and rejecting cycles
+func TestMoveTask(t *testing.T) {
+	account, token := createListingAccount("subtree-move@taskmgmt.com")
+	a := createSubtask(account, "move-a", nil)
+	b := createSubtask(account, "move-b", a)
+	c := createSubtask(account, "move-c", b)
+	other := createSubtask(account, "move-other", nil)
+
+	resp := sendAs(token, "PUT", fmt.Sprintf("/tasks/%d/move", b.ID), controllers.MoveTaskRequest{ParentID: &other.ID})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	dao.GetDB().First(c, c.ID)
+	dao.GetDB().First(b, b.ID)
+	assert.Equal(t, other.ID, *b.ParentID)
+	assert.Equal(t, b.ID, *c.ParentID) // the subtree came along
+
+	// Moving a task under its own descendant or itself is a cycle
+	resp = sendAs(token, "PUT", fmt.Sprintf("/tasks/%d/move", other.ID), controllers.MoveTaskRequest{ParentID: &c.ID})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs(token, "PUT", fmt.Sprintf("/tasks/%d/move", other.ID), controllers.MoveTaskRequest{ParentID: &other.ID})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// A null parent makes the task top-level again
+	resp = sendAs(token, "PUT", fmt.Sprintf("/tasks/%d/move", b.ID), controllers.MoveTaskRequest{})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	dao.GetDB().First(b, b.ID)
+	assert.Nil(t, b.ParentID)
+}
+
+// Test that completing the last open subtask completes a roll-up parent and its ancestors
+func TestRollupCompletion(t *testing.T) {
+	account, token := createListingAccount("rollup@taskmgmt.com")
+	epic := &models.Task{Title: "rollup-epic", Description: "Rolls up", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID, RollupCompletion: true}
+	dao.GetDB().Create(epic)
+	story := &models.Task{Title: "rollup-story", Description: "Rolls up", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID, RollupCompletion: true, ParentID: &epic.ID}
+	dao.GetDB().Create(story)
+	first := createSubtask(account, "rollup-first", story)
+	second := createSubtask(account, "rollup-second", story)
+
+	complete := func(task *models.Task) {
+		resp := sendAs(token, "PUT", fmt.Sprintf("/tasks/%d", task.ID), map[string]string{"status": "completed"})
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	complete(first)
+	dao.GetDB().First(story, story.ID)
+	assert.Equal(t, models.StatusPending, story.Status)
+
+	complete(second)
+	dao.GetDB().First(story, story.ID)
+	dao.GetDB().First(epic, epic.ID)
+	assert.Equal(t, models.StatusCompleted, story.Status)
+	assert.Equal(t, models.StatusCompleted, epic.Status)
+}
+
+// Test that deleting a task hands its subtasks to its parent
+func TestDeleteTask_ReparentsSubtasks(t *testing.T) {
+	account, token := createListingAccount("subtree-delete@taskmgmt.com")
+	epic := createSubtask(account, "delete-epic", nil)
+	middle := createSubtask(account, "delete-middle", epic)
+	leaf := createSubtask(account, "delete-leaf", middle)
+
+	resp := sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d", middle.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	dao.GetDB().First(leaf, leaf.ID)
+	assert.Equal(t, epic.ID, *leaf.ParentID)
+}
+
+// Test that deleting a task rolls up a parent left with only completed subtasks
+func TestDeleteTask_RollsUpParent(t *testing.T) {
+	account, token := createListingAccount("subtree-delete-rollup@taskmgmt.com")
+	epic := &models.Task{Title: "delete-rollup-epic", Description: "Rolls up", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID, RollupCompletion: true}
+	dao.GetDB().Create(epic)
+	middle := createSubtask(account, "delete-rollup-middle", epic)
+	leaf := createSubtask(account, "delete-rollup-leaf", middle)
+	dao.GetDB().Model(leaf).Update("status", models.StatusCompleted)
+
+	resp := sendAs(token, "DELETE", fmt.Sprintf("/tasks/%d", middle.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	dao.GetDB().First(epic, epic.ID)
+	assert.Equal(t, models.StatusCompleted, epic.Status)
+}
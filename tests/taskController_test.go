@@ -3,14 +3,17 @@ package tests_test
 import (
 	"bytes"
 	"encoding/json"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
 	"task-management/controllers"
 	"task-management/dao"
+	"task-management/jwtkeys"
 	"task-management/models"
 	"task-management/routes"
+	"task-management/storage"
 	"testing"
 	"time"
 
@@ -21,8 +24,12 @@ import (
 	"gorm.io/gorm"
 )
 
-var testToken = generateTestToken()
+var testToken string
 var testRouter *gin.Engine
+var testAccount models.Account
+
+// The search backend the tests expect; builds with -tags sqlite_fts5 expect FTS5
+var wantSearchBackend = dao.SearchLike
 
 const tokenKey = "token_password"
 const tokenVal = "thisIsTheJwtPassword"
@@ -36,8 +43,48 @@ func TestMain(m *testing.M) {
 	// Setup an in-memory SQLite DB
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	dao.SetDb(db)
+	dao.GetDB().AutoMigrate(&models.Workspace{})
+	dao.GetDB().AutoMigrate(&models.WorkspaceMember{})
+	dao.GetDB().AutoMigrate(&models.Invitation{})
+	dao.GetDB().AutoMigrate(&models.Project{})
 	dao.GetDB().AutoMigrate(&models.Task{})
 	dao.GetDB().AutoMigrate(&models.Account{})
+	dao.GetDB().AutoMigrate(&models.RefreshToken{})
+	dao.GetDB().AutoMigrate(&models.RevokedToken{})
+	dao.GetDB().AutoMigrate(&models.PasswordReset{})
+	dao.GetDB().AutoMigrate(&models.PersonalAccessToken{})
+	dao.GetDB().AutoMigrate(&models.RecoveryCode{})
+	dao.GetDB().AutoMigrate(&models.LoginThrottle{})
+	dao.GetDB().AutoMigrate(&models.AuthEvent{})
+	dao.GetDB().AutoMigrate(&models.AccountIdentity{})
+	dao.GetDB().AutoMigrate(&models.OIDCLogin{})
+	dao.GetDB().AutoMigrate(&models.SigningKey{})
+	dao.GetDB().AutoMigrate(&models.AuditLog{})
+	dao.GetDB().AutoMigrate(&models.Comment{})
+	dao.GetDB().AutoMigrate(&models.CommentRevision{})
+	dao.GetDB().AutoMigrate(&models.Attachment{})
+	dao.GetDB().AutoMigrate(&models.OrphanedBlob{})
+	dao.GetDB().AutoMigrate(&models.Activity{})
+	dao.GetDB().AutoMigrate(&models.Label{})
+	dao.GetDB().AutoMigrate(&models.TaskDependency{})
+	// A build that should have FTS5 must not quietly test the LIKE fallback instead
+	if err := dao.SetupTaskSearch(); dao.GetSearchBackend() != wantSearchBackend {
+		log.Fatalf("search backend is %s, want %s: %v", dao.GetSearchBackend(), wantSearchBackend, err)
+	}
+	// Tokens are signed with a key sealed by the token password
+	os.Setenv(tokenKey, tokenVal)
+	models.InitSigningKeys(jwtkeys.RS256, time.Hour)
+	// Attachments go to a scratch directory
+	blobDir, _ := os.MkdirTemp("", "blobs")
+	defer os.RemoveAll(blobDir)
+	storage.SetStore(&storage.FileStore{Root: blobDir})
+
+	// The account the test token's username claim resolves to
+	testAccount = models.Account{Email: "testuser", Password: "testpassword"}
+	dao.GetDB().Create(&testAccount)
+	models.EnsureActiveWorkspace(&testAccount)
+	testToken = generateTestToken()
+
 	// Setup routes
 	routes.SetupRoutes(testRouter)
 
@@ -54,18 +101,42 @@ func createTestTask() models.Task {
 		Title:       "Valid Task",
 		Description: "A valid task description",
 		Status:      "pending",
+		AccountID:   testAccount.ID,
+		WorkspaceID: *testAccount.ActiveWorkspaceID,
 	}
 	dao.GetDB().Create(&task)
 	return task
 }
 
-// Test listing public tasks
-func TestGetPublicTasks(t *testing.T) {
-	req, _ := http.NewRequest("GET", "/public/tasks", nil)
+// Test listing the caller's tasks
+func TestGetAllTasks(t *testing.T) {
+	// A task owned by someone else must not be listed
+	other := models.Account{Email: "list-other@taskmgmt.com", Password: "password"}
+	dao.GetDB().Create(&other)
+	models.EnsureActiveWorkspace(&other)
+	dao.GetDB().Create(&models.Task{Title: "Someone else's task", Description: "Not yours", Status: "pending", AccountID: other.ID, WorkspaceID: *other.ActiveWorkspaceID})
+
+	req, _ := http.NewRequest("GET", "/tasks/", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
 	resp := httptest.NewRecorder()
 	testRouter.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var page controllers.TaskPage
+	json.Unmarshal(resp.Body.Bytes(), &page)
+	for _, task := range page.Data {
+		assert.Equal(t, testAccount.ID, task.AccountID)
+	}
+}
+
+// Test listing without a token
+func TestGetAllTasks_Unauthorized(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/tasks/", nil)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
 }
 
 // Test creating a task (Protected Route)
@@ -91,7 +162,7 @@ func TestCreateTask(t *testing.T) {
 // Test retrieving a task by ID
 func TestGetTaskByID(t *testing.T) {
 	// Create a task
-	task := models.Task{Title: "Fetch Task", Description: "Fetch test", Status: "pending"}
+	task := models.Task{Title: "Fetch Task", Description: "Fetch test", Status: "pending", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
 	dao.GetDB().Create(&task)
 
 	// Fetch the task
@@ -103,10 +174,26 @@ func TestGetTaskByID(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.Code)
 }
 
+// ❌ **Test: Get a Task Owned by Another Account**
+func TestGetTaskByID_OtherAccount(t *testing.T) {
+	other := models.Account{Email: "fetch-other@taskmgmt.com", Password: "password"}
+	dao.GetDB().Create(&other)
+	models.EnsureActiveWorkspace(&other)
+	task := models.Task{Title: "Private Task", Description: "Owned by someone else", Status: "pending", AccountID: other.ID, WorkspaceID: *other.ActiveWorkspaceID}
+	dao.GetDB().Create(&task)
+
+	req, _ := http.NewRequest("GET", "/tasks/"+strconv.Itoa(int(task.ID)), nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
 // Test updating a task
 func TestUpdateTask(t *testing.T) {
 	// Create a task
-	task := models.Task{Title: "Old Title", Description: "Old Desc", Status: "pending"}
+	task := models.Task{Title: "Old Title", Description: "Old Desc", Status: "pending", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
 	dao.GetDB().Create(&task)
 
 	// Update data
@@ -126,7 +213,7 @@ func TestUpdateTask(t *testing.T) {
 // Test deleting a task
 func TestDeleteTask(t *testing.T) {
 	// Create a task
-	task := models.Task{Title: "To be deleted", Description: "Delete me", Status: "pending"}
+	task := models.Task{Title: "To be deleted", Description: "Delete me", Status: "pending", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
 	dao.GetDB().Create(&task)
 
 	// Delete request
@@ -167,6 +254,21 @@ func TestCreateTask_MissingFields(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 }
 
+// ❌ **Test: Create Task Starting After its Due Date**
+func TestCreateTask_StartAfterDue(t *testing.T) {
+	taskJSON := []byte(`{"title": "Backwards Task", "description": "Starts after it is due",
+		"start_at": "2030-01-02T00:00:00Z", "due_at": "2030-01-01T00:00:00Z"}`)
+
+	req, _ := http.NewRequest("POST", "/tasks/", bytes.NewBuffer(taskJSON))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
 // ❌ **Test: Create Duplicate Task**
 func TestCreateTask_Duplicate(t *testing.T) {
 	task := createTestTask() // Insert a task into DB
@@ -228,6 +330,8 @@ func TestUpdateTask_NotFound(t *testing.T) {
 
 // ❌ **Test: Update Task with Invalid Data**
 func TestUpdateTask_InvalidData(t *testing.T) {
+	task := models.Task{Title: "Invalid Update", Description: "Update me badly", Status: "pending", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
+	dao.GetDB().Create(&task)
 
 	taskUpdate := models.Task{
 		Title:       "", // Empty title (invalid)
@@ -236,7 +340,7 @@ func TestUpdateTask_InvalidData(t *testing.T) {
 	}
 	taskJSON, _ := json.Marshal(taskUpdate)
 
-	req, _ := http.NewRequest("PUT", "/tasks/"+strconv.Itoa(1), bytes.NewBuffer(taskJSON))
+	req, _ := http.NewRequest("PUT", "/tasks/"+strconv.Itoa(int(task.ID)), bytes.NewBuffer(taskJSON))
 	req.Header.Set("Authorization", "Bearer "+testToken)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -277,6 +381,23 @@ func TestDeleteTask_NotFound(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, resp.Code)
 }
 
+// ❌ **Test: Delete a Task Owned by Another Account**
+func TestDeleteTask_OtherAccount(t *testing.T) {
+	other := models.Account{Email: "delete-other@taskmgmt.com", Password: "password"}
+	dao.GetDB().Create(&other)
+	models.EnsureActiveWorkspace(&other)
+	task := models.Task{Title: "Keep me", Description: "Owned by someone else", Status: "pending", AccountID: other.ID, WorkspaceID: *other.ActiveWorkspaceID}
+	dao.GetDB().Create(&task)
+
+	req, _ := http.NewRequest("DELETE", "/tasks/"+strconv.Itoa(int(task.ID)), nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.NoError(t, dao.GetDB().First(&models.Task{}, task.ID).Error)
+}
+
 // ❌ **Test: Delete Task Without Authorization**
 func TestDeleteTask_Unauthorized(t *testing.T) {
 	task := createTestTask() // Insert a test task
@@ -290,12 +411,70 @@ func TestDeleteTask_Unauthorized(t *testing.T) {
 }
 
 func generateTestToken() string {
-	os.Setenv(tokenKey, tokenVal)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": "testuser",
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // Expire in 24 hours
-	})
+	return generateTokenFor("testuser")
+}
 
-	signedToken, _ := token.SignedString([]byte("thisIsTheJwtPassword")) // Use same key as middleware
+func generateTokenFor(username string) string {
+	signedToken, _ := jwtkeys.GetKeyring().Sign(jwt.MapClaims{
+		"username": username,
+		"jti":      models.RandomToken(16),
+		"exp":      time.Now().Add(24 * time.Hour).Unix(), // Expire in 24 hours
+	}) // Signed with the current key, as the middleware expects
 	return signedToken
 }
+
+// Helper function to send a bulk priority update
+func bulkUpdatePriority(ids []uint, priority string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(controllers.BulkPriorityRequest{IDs: ids, Priority: models.TaskPriority(priority)})
+	req, _ := http.NewRequest("PATCH", "/tasks/priority", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+	return resp
+}
+
+// Test re-prioritising several tasks at once
+func TestBulkUpdatePriority(t *testing.T) {
+	first := models.Task{Title: "Bulk One", Description: "Bulk priority", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
+	second := models.Task{Title: "Bulk Two", Description: "Bulk priority", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
+	dao.GetDB().Create(&first)
+	dao.GetDB().Create(&second)
+	assert.Equal(t, models.PriorityMedium, first.Priority)
+
+	resp := bulkUpdatePriority([]uint{first.ID, second.ID}, "urgent")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var updated []models.Task
+	dao.GetDB().Find(&updated, []uint{first.ID, second.ID})
+	for _, task := range updated {
+		assert.Equal(t, models.PriorityUrgent, task.Priority)
+	}
+}
+
+// ❌ **Test: Bulk Priority Update Including Another Account's Task**
+func TestBulkUpdatePriority_OtherAccount(t *testing.T) {
+	other := models.Account{Email: "bulk-other@taskmgmt.com", Password: "password"}
+	dao.GetDB().Create(&other)
+	models.EnsureActiveWorkspace(&other)
+	mine := models.Task{Title: "Bulk Mine", Description: "Bulk priority", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
+	theirs := models.Task{Title: "Bulk Theirs", Description: "Bulk priority", AccountID: other.ID, WorkspaceID: *other.ActiveWorkspaceID}
+	dao.GetDB().Create(&mine)
+	dao.GetDB().Create(&theirs)
+
+	resp := bulkUpdatePriority([]uint{mine.ID, theirs.ID}, "high")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	dao.GetDB().First(&mine, mine.ID)
+	assert.Equal(t, models.PriorityMedium, mine.Priority)
+}
+
+// ❌ **Test: Bulk Priority Update with an Unknown Priority**
+func TestBulkUpdatePriority_InvalidPriority(t *testing.T) {
+	task := models.Task{Title: "Bulk Invalid", Description: "Bulk priority", AccountID: testAccount.ID, WorkspaceID: *testAccount.ActiveWorkspaceID}
+	dao.GetDB().Create(&task)
+
+	resp := bulkUpdatePriority([]uint{task.ID}, "whenever")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
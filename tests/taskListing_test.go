@@ -0,0 +1,226 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to create an account with its own token and a few tasks
+func createListingAccount(email string, titles ...string) (models.Account, string) {
+	account := models.Account{Email: email, Password: "password"}
+	dao.GetDB().Create(&account)
+	models.EnsureActiveWorkspace(&account)
+	for i, title := range titles {
+		status := models.StatusPending
+		if i%2 == 1 {
+			status = models.StatusCompleted
+		}
+		dao.GetDB().Create(&models.Task{Title: title, Description: "Listing test task", Status: status, AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID})
+	}
+	return account, generateTokenFor(email)
+}
+
+// Helper function to fetch one page of the task listing
+func listTasks(t *testing.T, token string, query url.Values) (int, controllers.TaskPage) {
+	req, _ := http.NewRequest("GET", "/tasks/?"+query.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	var page controllers.TaskPage
+	json.Unmarshal(resp.Body.Bytes(), &page)
+	return resp.Code, page
+}
+
+func titles(tasks []models.Task) []string {
+	result := []string{}
+	for _, task := range tasks {
+		result = append(result, task.Title)
+	}
+	return result
+}
+
+// Test walking forwards and backwards through the pages of a listing
+func TestListTasks_CursorPagination(t *testing.T) {
+	_, token := createListingAccount("pages@taskmgmt.com", "page-a", "page-b", "page-c", "page-d", "page-e")
+
+	code, first := listTasks(t, token, url.Values{"limit": {"2"}, "sort": {"title"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(5), first.Total)
+	assert.Equal(t, []string{"page-a", "page-b"}, titles(first.Data))
+	assert.Nil(t, first.PrevCursor)
+	assert.NotNil(t, first.NextCursor)
+
+	_, second := listTasks(t, token, url.Values{"limit": {"2"}, "cursor": {*first.NextCursor}})
+	assert.Equal(t, []string{"page-c", "page-d"}, titles(second.Data))
+	assert.NotNil(t, second.PrevCursor)
+
+	_, third := listTasks(t, token, url.Values{"limit": {"2"}, "cursor": {*second.NextCursor}})
+	assert.Equal(t, []string{"page-e"}, titles(third.Data))
+	assert.Nil(t, third.NextCursor)
+
+	_, back := listTasks(t, token, url.Values{"limit": {"2"}, "cursor": {*third.PrevCursor}})
+	assert.Equal(t, []string{"page-c", "page-d"}, titles(back.Data))
+	assert.NotNil(t, back.NextCursor)
+
+	_, start := listTasks(t, token, url.Values{"limit": {"2"}, "cursor": {*back.PrevCursor}})
+	assert.Equal(t, []string{"page-a", "page-b"}, titles(start.Data))
+	assert.Nil(t, start.PrevCursor)
+}
+
+// Test clients may send the sort again along with its cursor, but not a different one
+func TestListTasks_CursorWithSort(t *testing.T) {
+	_, token := createListingAccount("resort@taskmgmt.com", "resort-a", "resort-b", "resort-c", "resort-d")
+
+	_, first := listTasks(t, token, url.Values{"limit": {"2"}, "sort": {"-due_at,title"}})
+	assert.Equal(t, []string{"resort-a", "resort-b"}, titles(first.Data))
+	code, second := listTasks(t, token, url.Values{"limit": {"2"}, "sort": {"-due_at,title"}, "cursor": {*first.NextCursor}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"resort-c", "resort-d"}, titles(second.Data))
+
+	code, _ = listTasks(t, token, url.Values{"limit": {"2"}, "sort": {"-title"}, "cursor": {*first.NextCursor}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// Test sorting on several fields with mixed directions
+func TestListTasks_MultiFieldSort(t *testing.T) {
+	_, token := createListingAccount("sorting@taskmgmt.com", "sort-a", "sort-b", "sort-c", "sort-d")
+
+	_, page := listTasks(t, token, url.Values{"sort": {"status,-title"}})
+	assert.Equal(t, []string{"sort-d", "sort-b", "sort-c", "sort-a"}, titles(page.Data))
+
+	_, next := listTasks(t, token, url.Values{"sort": {"status,-title"}, "limit": {"3"}})
+	_, rest := listTasks(t, token, url.Values{"limit": {"3"}, "cursor": {*next.NextCursor}})
+	assert.Equal(t, []string{"sort-a"}, titles(rest.Data))
+}
+
+// Test filtering on status and title substring
+func TestListTasks_Filters(t *testing.T) {
+	_, token := createListingAccount("filters@taskmgmt.com", "Filter Alpha", "Filter Beta", "Filter Gamma", "Other")
+
+	_, page := listTasks(t, token, url.Values{"status": {"pending"}, "title": {"filter"}, "sort": {"title"}})
+	assert.Equal(t, int64(2), page.Total)
+	assert.Equal(t, []string{"Filter Alpha", "Filter Gamma"}, titles(page.Data))
+
+	_, page = listTasks(t, token, url.Values{"created_after": {"2999-01-01T00:00:00Z"}})
+	assert.Equal(t, int64(0), page.Total)
+	assert.Empty(t, page.Data)
+}
+
+// ❌ **Test: Listing with Invalid Parameters**
+func TestListTasks_InvalidParameters(t *testing.T) {
+	for _, query := range []url.Values{
+		{"sort": {"password"}},
+		{"status": {"archived"}},
+		{"limit": {"0"}},
+		{"created_before": {"yesterday"}},
+		{"cursor": {"not-a-cursor"}},
+	} {
+		code, _ := listTasks(t, testToken, query)
+		assert.Equal(t, http.StatusBadRequest, code, fmt.Sprint(query))
+	}
+}
+
+// Helper function to create a task due at the given offset from now
+func createDueTask(account models.Account, title string, status models.TaskStatus, due *time.Duration) {
+	task := models.Task{Title: title, Description: "Deadline test task", Status: status, AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID}
+	if due != nil {
+		dueAt := time.Now().Add(*due)
+		task.DueAt = &dueAt
+	}
+	dao.GetDB().Create(&task)
+}
+
+func hours(n int) *time.Duration {
+	d := time.Duration(n) * time.Hour
+	return &d
+}
+
+// Test the overdue and due_within filters and the computed deadline fields
+func TestListTasks_DeadlineFilters(t *testing.T) {
+	account, token := createListingAccount("deadlines@taskmgmt.com")
+	createDueTask(account, "due-late", models.StatusPending, hours(-30))
+	createDueTask(account, "due-done", models.StatusCompleted, hours(-30))
+	createDueTask(account, "due-soon", models.StatusPending, hours(30))
+	createDueTask(account, "due-later", models.StatusPending, hours(24*10))
+	createDueTask(account, "due-never", models.StatusPending, nil)
+
+	_, page := listTasks(t, token, url.Values{"overdue": {"true"}})
+	assert.Equal(t, []string{"due-late"}, titles(page.Data))
+	assert.True(t, page.Data[0].IsOverdue)
+	if assert.NotNil(t, page.Data[0].DaysRemaining) {
+		assert.Equal(t, -2, *page.Data[0].DaysRemaining)
+	}
+
+	_, page = listTasks(t, token, url.Values{"overdue": {"false"}})
+	assert.Equal(t, int64(4), page.Total)
+
+	_, page = listTasks(t, token, url.Values{"due_within": {"3d"}})
+	assert.Equal(t, []string{"due-soon"}, titles(page.Data))
+	assert.False(t, page.Data[0].IsOverdue)
+	assert.Equal(t, 1, *page.Data[0].DaysRemaining)
+
+	code, _ := listTasks(t, token, url.Values{"due_within": {"-1h"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// Test that tasks without a due date sort last in both directions and page correctly
+func TestListTasks_SortByDueDate(t *testing.T) {
+	account, token := createListingAccount("due-sort@taskmgmt.com")
+	createDueTask(account, "sort-due-none-1", models.StatusPending, nil)
+	createDueTask(account, "sort-due-2", models.StatusPending, hours(48))
+	createDueTask(account, "sort-due-1", models.StatusPending, hours(24))
+	createDueTask(account, "sort-due-none-2", models.StatusPending, nil)
+
+	_, page := listTasks(t, token, url.Values{"sort": {"-due_at"}})
+	assert.Equal(t, []string{"sort-due-2", "sort-due-1", "sort-due-none-1", "sort-due-none-2"}, titles(page.Data))
+
+	var walked []string
+	query := url.Values{"sort": {"due_at"}, "limit": {"1"}}
+	for {
+		_, page := listTasks(t, token, query)
+		walked = append(walked, titles(page.Data)...)
+		if page.NextCursor == nil {
+			break
+		}
+		query = url.Values{"limit": {"1"}, "cursor": {*page.NextCursor}}
+	}
+	assert.Equal(t, []string{"sort-due-1", "sort-due-2", "sort-due-none-1", "sort-due-none-2"}, walked)
+}
+
+// Test triage ordering: most urgent first, then earliest due date
+func TestListTasks_SortByPriority(t *testing.T) {
+	account, token := createListingAccount("priority@taskmgmt.com")
+	for _, task := range []models.Task{
+		{Title: "prio-low", Priority: models.PriorityLow},
+		{Title: "prio-urgent-late", Priority: models.PriorityUrgent, DueAt: timePtr(48)},
+		{Title: "prio-medium", Priority: models.PriorityMedium},
+		{Title: "prio-urgent-soon", Priority: models.PriorityUrgent, DueAt: timePtr(24)},
+		{Title: "prio-high", Priority: models.PriorityHigh},
+	} {
+		task.Description = "Priority test task"
+		task.AccountID, task.WorkspaceID = account.ID, *account.ActiveWorkspaceID
+		dao.GetDB().Create(&task)
+	}
+
+	_, page := listTasks(t, token, url.Values{"sort": {"-priority,due_at"}})
+	assert.Equal(t, []string{"prio-urgent-soon", "prio-urgent-late", "prio-high", "prio-medium", "prio-low"}, titles(page.Data))
+
+	_, page = listTasks(t, token, url.Values{"priority": {"high,urgent"}})
+	assert.Equal(t, int64(3), page.Total)
+}
+
+func timePtr(hoursFromNow int) *time.Time {
+	t := time.Now().Add(time.Duration(hoursFromNow) * time.Hour)
+	return &t
+}
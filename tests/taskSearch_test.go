@@ -0,0 +1,72 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to run a search as the holder of token
+func searchTasks(t *testing.T, token, q string) (int, []models.TaskSearchResult) {
+	req, _ := http.NewRequest("GET", "/tasks/search?"+url.Values{"q": {q}}.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	var body struct {
+		Data []models.TaskSearchResult `json:"data"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	return resp.Code, body.Data
+}
+
+// Test that title matches rank first and snippets are highlighted
+func TestSearchTasks(t *testing.T) {
+	account, token := createListingAccount("search@taskmgmt.com")
+	dao.GetDB().Create(&models.Task{Title: "Write release notes", Description: "Summarise the deployment changes", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID})
+	dao.GetDB().Create(&models.Task{Title: "Deployment pipeline", Description: "Automate the deployment to staging", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID})
+	dao.GetDB().Create(&models.Task{Title: "Team lunch", Description: "Book a table for friday", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID})
+
+	code, results := searchTasks(t, token, "deployment")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "Deployment pipeline", results[0].Title)
+		assert.Contains(t, results[0].TitleSnippet, "<mark>Deployment</mark>")
+		assert.Contains(t, results[1].DescriptionSnippet, "<mark>deployment</mark>")
+	}
+}
+
+// Test that task text in snippets is escaped, leaving <mark> as the only markup
+func TestSearchTasks_EscapesSnippets(t *testing.T) {
+	account, token := createListingAccount("search-escape@taskmgmt.com")
+	dao.GetDB().Create(&models.Task{Title: `<img src=x onerror="alert(1)"> script`, Description: "Check the <b>script</b> & tags", AccountID: account.ID, WorkspaceID: *account.ActiveWorkspaceID})
+
+	code, results := searchTasks(t, token, "script")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>script</mark>`, results[0].TitleSnippet)
+		assert.Equal(t, `Check the &lt;b&gt;<mark>script</mark>&lt;/b&gt; &amp; tags`, results[0].DescriptionSnippet)
+	}
+}
+
+// Test that search only sees the caller's tasks
+func TestSearchTasks_OtherAccount(t *testing.T) {
+	other, _ := createListingAccount("search-other@taskmgmt.com")
+	dao.GetDB().Create(&models.Task{Title: "Quarterly budget", Description: "Confidential numbers", AccountID: other.ID, WorkspaceID: *other.ActiveWorkspaceID})
+
+	code, results := searchTasks(t, testToken, "budget")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, results)
+}
+
+// ❌ **Test: Search Without a Query**
+func TestSearchTasks_MissingQuery(t *testing.T) {
+	code, _ := searchTasks(t, testToken, "  ")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
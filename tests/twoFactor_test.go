@@ -0,0 +1,114 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"task-management/controllers"
+	"task-management/totp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type twoFactorResponse struct {
+	Secret            string   `json:"secret"`
+	ProvisioningURI   string   `json:"provisioning_uri"`
+	RecoveryCodes     []string `json:"recovery_codes"`
+	TwoFactorRequired bool     `json:"two_factor_required"`
+	ChallengeToken    string   `json:"challenge_token"`
+}
+
+// Helper function to turn on two-factor login, returning the secret and recovery codes
+func enableTwoFactor(t *testing.T, token string) (string, []string) {
+	var enrolled twoFactorResponse
+	resp := sendAs(token, "POST", "/2fa/enroll", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &enrolled)
+
+	code, _ := totp.Code(enrolled.Secret, time.Now())
+	var confirmed twoFactorResponse
+	resp = sendAs(token, "POST", "/2fa/confirm", controllers.TwoFactorCodeRequest{Code: code})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	json.Unmarshal(resp.Body.Bytes(), &confirmed)
+	return enrolled.Secret, confirmed.RecoveryCodes
+}
+
+// Helper function for the password step of a two-factor login
+func challenge(t *testing.T, email string) string {
+	resp := sendAs("", "POST", "/login", controllers.LoginRequest{Email: email, Password: "password123"})
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+
+	var body twoFactorResponse
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.True(t, body.TwoFactorRequired)
+	assert.NotContains(t, resp.Body.String(), `"token"`)
+	return body.ChallengeToken
+}
+
+// Test enrolment hands out a provisioning URI and only takes effect once confirmed
+func TestTwoFactor_Enroll(t *testing.T) {
+	session := login(t, "2fa-enroll@taskmgmt.com")
+
+	var enrolled twoFactorResponse
+	resp := sendAs(session.Token, "POST", "/2fa/enroll", nil)
+	json.Unmarshal(resp.Body.Bytes(), &enrolled)
+	assert.NotEmpty(t, enrolled.Secret)
+	assert.True(t, strings.HasPrefix(enrolled.ProvisioningURI, "otpauth://totp/"))
+	assert.Contains(t, enrolled.ProvisioningURI, "secret="+enrolled.Secret)
+
+	// Not confirmed yet, so the password is still enough
+	loginAs(t, "2fa-enroll@taskmgmt.com", "password123")
+	resp = sendAs(session.Token, "POST", "/2fa/confirm", controllers.TwoFactorCodeRequest{Code: "000000"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	_, codes := enableTwoFactor(t, session.Token)
+	assert.Len(t, codes, 10)
+	resp = sendAs(session.Token, "POST", "/2fa/enroll", nil)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+// Test login asks for a code, and each code only works once
+func TestTwoFactor_Login(t *testing.T) {
+	session := login(t, "2fa-login@taskmgmt.com")
+	secret, _ := enableTwoFactor(t, session.Token)
+	challengeToken := challenge(t, "2fa-login@taskmgmt.com")
+
+	resp := sendAs("", "POST", "/login/2fa", controllers.TwoFactorLoginRequest{ChallengeToken: challengeToken, Code: "123456"})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	// The code used to confirm enrolment is spent, so use the next one
+	code, _ := totp.Code(secret, time.Now().Add(totp.Period*time.Second))
+	resp = sendAs("", "POST", "/login/2fa", controllers.TwoFactorLoginRequest{ChallengeToken: challengeToken, Code: code})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var tokens sessionTokens
+	json.Unmarshal(resp.Body.Bytes(), &tokens)
+	resp = sendAs(tokens.Token, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = sendAs("", "POST", "/login/2fa", controllers.TwoFactorLoginRequest{ChallengeToken: challenge(t, "2fa-login@taskmgmt.com"), Code: code})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	// A challenge is not an access token
+	resp = sendAs(challengeToken, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+// Test recovery codes stand in for the authenticator once each, and can turn 2FA off
+func TestTwoFactor_RecoveryCodes(t *testing.T) {
+	session := login(t, "2fa-recovery@taskmgmt.com")
+	_, codes := enableTwoFactor(t, session.Token)
+
+	resp := sendAs("", "POST", "/login/2fa", controllers.TwoFactorLoginRequest{ChallengeToken: challenge(t, "2fa-recovery@taskmgmt.com"), Code: codes[0]})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"recovery_codes_remaining":9`)
+	resp = sendAs("", "POST", "/login/2fa", controllers.TwoFactorLoginRequest{ChallengeToken: challenge(t, "2fa-recovery@taskmgmt.com"), Code: codes[0]})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = sendAs(session.Token, "POST", "/2fa/disable", controllers.DisableTwoFactorRequest{Password: "wrong-password", Code: codes[1]})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = sendAs(session.Token, "POST", "/2fa/disable", controllers.DisableTwoFactorRequest{Password: "password123", Code: codes[1]})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	loginAs(t, "2fa-recovery@taskmgmt.com", "password123")
+}
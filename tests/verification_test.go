@@ -0,0 +1,91 @@
+package tests_test
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"task-management/controllers"
+	"task-management/dao"
+	"task-management/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var verifyLinkPattern = regexp.MustCompile(`/account/verify\?token=\S+`)
+
+// Helper function to pull the verification link out of the mail sent by fn
+func verificationLink(t *testing.T, fn func()) string {
+	sent := captureMail(t, fn)
+	if len(sent) == 0 {
+		return ""
+	}
+	return verifyLinkPattern.FindString(sent[0].Body)
+}
+
+// Test new accounts can only log in once their email is verified
+func TestVerifyEmail(t *testing.T) {
+	link := verificationLink(t, func() {
+		resp := sendAs("", "POST", "/account", models.Account{Email: "verify@taskmgmt.com", Password: "password123"})
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	})
+	assert.NotEmpty(t, link)
+
+	resp := sendAs("", "POST", "/login", controllers.LoginRequest{Email: "verify@taskmgmt.com", Password: "password123"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = sendAs("", "GET", link, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	loginAs(t, "verify@taskmgmt.com", "password123")
+}
+
+// Test sign-up cannot mark its own account verified
+func TestVerifyEmail_NotFromSignUp(t *testing.T) {
+	now := time.Now()
+	captureMail(t, func() {
+		resp := sendAs("", "POST", "/account", models.Account{Email: "self-verified@taskmgmt.com", Password: "password123", VerifiedAt: &now})
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	})
+	resp := sendAs("", "POST", "/login", controllers.LoginRequest{Email: "self-verified@taskmgmt.com", Password: "password123"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+// Test a second sign-up with a taken address is refused and mails nothing
+func TestCreateAccount_DuplicateEmail(t *testing.T) {
+	first := login(t, "taken@taskmgmt.com")
+	sent := captureMail(t, func() {
+		resp := sendAs("", "POST", "/account", models.Account{Email: "taken@taskmgmt.com", Password: "other-password"})
+		assert.Contains(t, resp.Body.String(), "already in use")
+	})
+	assert.Empty(t, sent)
+	loginAs(t, "taken@taskmgmt.com", "password123")
+	assert.Equal(t, http.StatusOK, sendAs(first.Token, "GET", "/me", nil).Code)
+
+	// The database refuses it too, should two sign-ups race
+	assert.Error(t, dao.GetDB().Create(&models.Account{Email: "taken@taskmgmt.com", Password: "x"}).Error)
+}
+
+// Test resending verification, and links that were tampered with
+func TestResendVerification(t *testing.T) {
+	captureMail(t, func() {
+		sendAs("", "POST", "/account", models.Account{Email: "resend@taskmgmt.com", Password: "password123"})
+	})
+	link := verificationLink(t, func() {
+		resp := sendAs("", "POST", "/account/verify/resend", controllers.ResendVerificationRequest{Email: "resend@taskmgmt.com"})
+		assert.Equal(t, http.StatusOK, resp.Code)
+	})
+	assert.NotEmpty(t, link)
+
+	resp := sendAs("", "GET", link+"x", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs("", "GET", "/account/verify?token="+url.QueryEscape(generateTokenFor("resend@taskmgmt.com")), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = sendAs("", "GET", link, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// Verified accounts get no more links
+	assert.Empty(t, verificationLink(t, func() {
+		sendAs("", "POST", "/account/verify/resend", controllers.ResendVerificationRequest{Email: "resend@taskmgmt.com"})
+	}))
+}
@@ -0,0 +1,142 @@
+package tests_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"task-management/mailer"
+	"task-management/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mailer that keeps messages so tests can read the links in them
+type captureMailer struct {
+	sent []mailer.Message
+}
+
+func (m *captureMailer) Send(msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var tokenPattern = regexp.MustCompile(`[\w-]{10,}\.[\w-]{10,}\.[\w-]{10,}`)
+
+// Helper function to capture mail sent while fn runs
+func captureMail(t *testing.T, fn func()) []mailer.Message {
+	prev := mailer.GetMailer()
+	t.Cleanup(func() { mailer.SetMailer(prev) })
+	capture := &captureMailer{}
+	mailer.SetMailer(capture)
+	fn()
+	return capture.sent
+}
+
+// Helper function to create a workspace through the API
+func createWorkspace(t *testing.T, token, name string) models.Workspace {
+	resp := sendAs(token, "POST", "/workspaces/", map[string]string{"name": name})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var workspace models.Workspace
+	json.Unmarshal(resp.Body.Bytes(), &workspace)
+	return workspace
+}
+
+// Helper function to invite email to a workspace with role, returning the emailed token
+func invite(t *testing.T, token string, workspace models.Workspace, email string, role models.Role) string {
+	sent := captureMail(t, func() {
+		body := map[string]string{"email": email, "role": string(role)}
+		resp := sendAs(token, "POST", fmt.Sprintf("/workspaces/%d/invitations", workspace.ID), body)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	})
+	if !assert.Len(t, sent, 1) {
+		return ""
+	}
+	assert.Equal(t, email, sent[0].To)
+	return tokenPattern.FindString(sent[0].Body)
+}
+
+// Test inviting a member who then works on the workspace's tasks
+func TestInvitation_Accept(t *testing.T) {
+	_, ownerToken := createListingAccount("ws-owner@taskmgmt.com")
+	_, memberToken := createListingAccount("ws-member@taskmgmt.com", "Member personal task")
+	workspace := createWorkspace(t, ownerToken, "Acme")
+	sendAs(ownerToken, "POST", fmt.Sprintf("/workspaces/%d/activate", workspace.ID), nil)
+	sendAs(ownerToken, "POST", "/tasks/", map[string]string{"title": "Acme roadmap", "description": "Plan the year"})
+
+	invitation := invite(t, ownerToken, workspace, "ws-member@taskmgmt.com", models.RoleMember)
+	var pending []models.Invitation
+	resp := sendAs(memberToken, "GET", "/invitations/", nil)
+	json.Unmarshal(resp.Body.Bytes(), &pending)
+	assert.Len(t, pending, 1)
+
+	resp = sendAs(memberToken, "POST", "/invitations/accept", map[string]string{"token": invitation})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(memberToken, "POST", "/invitations/accept", map[string]string{"token": invitation})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// Until switching, the member still works in their personal workspace
+	_, page := listTasks(t, memberToken, nil)
+	assert.Equal(t, []string{"Member personal task"}, titles(page.Data))
+	resp = sendAs(memberToken, "POST", fmt.Sprintf("/workspaces/%d/activate", workspace.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	_, page = listTasks(t, memberToken, nil)
+	assert.Equal(t, []string{"Acme roadmap"}, titles(page.Data))
+
+	resp = sendAs(ownerToken, "GET", fmt.Sprintf("/workspaces/%d", workspace.ID), nil)
+	var body struct {
+		Members []models.MemberView `json:"members"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	assert.Len(t, body.Members, 2)
+}
+
+// ❌ **Test: Invitations for Someone Else, Declined or Used as Access Tokens**
+func TestInvitation_Invalid(t *testing.T) {
+	_, ownerToken := createListingAccount("ws-inviter@taskmgmt.com")
+	_, inviteeToken := createListingAccount("ws-invitee@taskmgmt.com")
+	_, strangerToken := createListingAccount("ws-stranger@taskmgmt.com")
+	workspace := createWorkspace(t, ownerToken, "Private")
+	invitation := invite(t, ownerToken, workspace, "ws-invitee@taskmgmt.com", models.RoleMember)
+
+	resp := sendAs(invitation, "GET", "/tasks/", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = sendAs(strangerToken, "POST", "/invitations/accept", map[string]string{"token": invitation})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	resp = sendAs(inviteeToken, "POST", "/invitations/accept", map[string]string{"token": "not-a-token"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = sendAs(inviteeToken, "POST", "/invitations/decline", map[string]string{"token": invitation})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = sendAs(inviteeToken, "POST", "/invitations/accept", map[string]string{"token": invitation})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// Only members can see or invite to a workspace
+	resp = sendAs(inviteeToken, "GET", fmt.Sprintf("/workspaces/%d", workspace.ID), nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+// Test removing a member sends them back to their personal workspace
+func TestRemoveMember(t *testing.T) {
+	_, ownerToken := createListingAccount("ws-remover@taskmgmt.com")
+	member, memberToken := createListingAccount("ws-removed@taskmgmt.com", "Removed personal task")
+	stranger, _ := createListingAccount("ws-remover-check@taskmgmt.com")
+	workspace := createWorkspace(t, ownerToken, "Shrinking")
+	sendAs(memberToken, "POST", "/invitations/accept", map[string]string{"token": invite(t, ownerToken, workspace, member.Email, models.RoleMember)})
+	sendAs(memberToken, "POST", fmt.Sprintf("/workspaces/%d/activate", workspace.ID), nil)
+
+	// Members cannot remove others, and nobody can remove the owner
+	resp := sendAs(memberToken, "DELETE", fmt.Sprintf("/workspaces/%d/members/%d", workspace.ID, stranger.ID), nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	resp = sendAs(ownerToken, "DELETE", fmt.Sprintf("/workspaces/%d/members/%d", workspace.ID, workspace.OwnerID), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = sendAs(ownerToken, "DELETE", fmt.Sprintf("/workspaces/%d/members/%d", workspace.ID, member.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	_, page := listTasks(t, memberToken, nil)
+	assert.Equal(t, []string{"Removed personal task"}, titles(page.Data))
+	resp = sendAs(memberToken, "GET", fmt.Sprintf("/workspaces/%d", workspace.ID), nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
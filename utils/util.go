@@ -3,6 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
+	"os"
+	"strings"
 )
 
 func Message(status bool, message string) map[string]interface{} {
@@ -13,3 +15,16 @@ func Respond(w http.ResponseWriter, data map[string]interface{}) {
 	w.Header().Add("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
+
+// EscapeLike escapes the wildcards of a LIKE pattern so s is matched literally
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// AppURL is the base URL of the API used in links sent to users
+func AppURL() string {
+	if url := os.Getenv("app_url"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:8080"
+}